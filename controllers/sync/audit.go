@@ -0,0 +1,288 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	gosync "sync"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+)
+
+// Actions recorded in the audit log
+const (
+	AuditManagedCreated           = "ManagedPolicyCreated"
+	AuditManagedReverted          = "ManagedPolicyReverted"
+	AuditManagedDeleted           = "ManagedPolicyDeleted"
+	AuditManagedConditionsUpdated = "ManagedConditionsUpdated"
+	AuditManagedStatusUpdated     = "ManagedStatusUpdated"
+	AuditHubStatusUpdated         = "HubStatusUpdated"
+)
+
+// AuditRecord is a line of the audit log, describing a change made by the controller.
+type AuditRecord struct {
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"`
+	Cluster   string    `json:"cluster"`
+	Namespace string    `json:"namespace"`
+	Policy    string    `json:"policy"`
+	// Reason explains why the change was made
+	Reason string `json:"reason"`
+	// Actor is the actor whose change of the managed policy was reverted
+	Actor string `json:"actor,omitempty"`
+	// Events are the names of the events, prefixed with the policy template name, that triggered a status change
+	Events []string `json:"events,omitempty"`
+	// Before and After are the changed part of the policy, such as its status, before and after the change
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+	// Diff is the JSON merge patch from Before to After
+	Diff json.RawMessage `json:"diff,omitempty"`
+}
+
+// policyContent is the part of a policy that is synced from the hub, as recorded in the audit log.
+type policyContent struct {
+	Labels      map[string]string     `json:"labels,omitempty"`
+	Annotations map[string]string     `json:"annotations,omitempty"`
+	Spec        policiesv1.PolicySpec `json:"spec"`
+}
+
+// auditContent returns the part of the input policy that is synced from the hub.
+func auditContent(plc *policiesv1.Policy) *policyContent {
+	return &policyContent{Labels: plc.GetLabels(), Annotations: plc.GetAnnotations(), Spec: plc.Spec}
+}
+
+// AuditLog writes the audit records as JSON Lines to a file, which is rotated when it reaches its maximum size, or to
+// another writer such as stdout. A nil AuditLog records nothing.
+type AuditLog struct {
+	lock gosync.Mutex
+	out  io.Writer
+	// path is the path of the file, or empty when writing to another writer
+	path       string
+	file       *os.File
+	size       int64
+	maxSize    int64
+	maxBackups int
+}
+
+// NewAuditLog returns an AuditLog writing to the file at the input path, or to stdout if the path is "-", which the
+// logs don't use since they are written to stderr. The file is rotated when it would exceed maxSize bytes, keeping
+// maxBackups rotated files with the .1, .2, ... suffixes. A maxSize of zero disables the rotation.
+func NewAuditLog(path string, maxSize int64, maxBackups int) (*AuditLog, error) {
+	if path == "-" {
+		return NewAuditLogWriter(os.Stdout), nil
+	}
+
+	auditLog := &AuditLog{path: path, maxSize: maxSize, maxBackups: maxBackups}
+
+	if err := auditLog.open(); err != nil {
+		return nil, err
+	}
+
+	return auditLog, nil
+}
+
+// NewAuditLogWriter returns an AuditLog writing to the input writer, without rotation.
+func NewAuditLogWriter(out io.Writer) *AuditLog {
+	return &AuditLog{out: out}
+}
+
+// open opens the audit log file for appending.
+func (a *AuditLog) open() error {
+	file, err := os.OpenFile(a.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+
+		return err
+	}
+
+	a.file = file
+	a.out = file
+	a.size = info.Size()
+
+	return nil
+}
+
+// rotate renames the audit log file to the first backup, shifting the older backups and removing the oldest one, and
+// opens a new file. The new file is opened even if the current file can't be closed or the backups can't be renamed,
+// since the records can't be written to the current file anymore.
+func (a *AuditLog) rotate() error {
+	if err := a.file.Close(); err != nil {
+		log.Error(err, "Failed to close the audit log before rotating it", "path", a.path)
+	}
+
+	rotateErr := a.renameBackups()
+
+	if err := a.open(); err != nil {
+		return err
+	}
+
+	return rotateErr
+}
+
+// renameBackups renames the audit log file to the first backup, shifting the older backups. Without backups, the
+// file is removed.
+func (a *AuditLog) renameBackups() error {
+	if a.maxBackups <= 0 {
+		if err := os.Remove(a.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		return nil
+	}
+
+	for i := a.maxBackups - 1; i > 0; i-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", a.path, i), fmt.Sprintf("%s.%d", a.path, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return os.Rename(a.path, a.path+".1")
+}
+
+// Close closes the audit log file. The records written afterwards are discarded. It does nothing when writing to
+// another writer, which isn't owned by the AuditLog.
+func (a *AuditLog) Close() error {
+	if a == nil {
+		return nil
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.out = io.Discard
+
+	if a.file == nil {
+		return nil
+	}
+
+	err := a.file.Close()
+	a.file = nil
+
+	return err
+}
+
+// Record writes the input record, computing its diff. Failures are only logged so they never fail a reconcile.
+func (a *AuditLog) Record(record AuditRecord) {
+	if a == nil {
+		return
+	}
+
+	if record.Time.IsZero() {
+		record.Time = time.Now().UTC()
+	}
+
+	if record.Diff == nil && (record.Before != nil || record.After != nil) {
+		record.Diff = auditDiff(record.Before, record.After)
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		log.Error(err, "Failed to marshal the audit record", "action", record.Action)
+
+		return
+	}
+
+	line = append(line, '\n')
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.file != nil && a.maxSize > 0 && a.size > 0 && a.size+int64(len(line)) > a.maxSize {
+		if err := a.rotate(); err != nil {
+			log.Error(err, "Failed to rotate the audit log", "path", a.path)
+		}
+	}
+
+	written, err := a.out.Write(line)
+	a.size += int64(written)
+
+	if err != nil {
+		log.Error(err, "Failed to write the audit record", "action", record.Action)
+	}
+}
+
+// audit records the input change of the input policy in the audit log of the reconciler, if any.
+func (r *PolicyReconciler) audit(plc *policiesv1.Policy, record AuditRecord) {
+	if r.AuditLog == nil {
+		return
+	}
+
+	record.Cluster = r.ClusterNamespaceOnHub
+	record.Namespace = plc.GetNamespace()
+	record.Policy = plc.GetName()
+
+	r.AuditLog.Record(record)
+}
+
+// auditDiff returns the JSON merge patch from before to after, or nil if it can't be computed.
+func auditDiff(before interface{}, after interface{}) json.RawMessage {
+	beforeJSON, err := json.Marshal(before)
+	if err != nil {
+		return nil
+	}
+
+	afterJSON, err := json.Marshal(after)
+	if err != nil {
+		return nil
+	}
+
+	if before == nil {
+		beforeJSON = []byte("{}")
+	}
+
+	if after == nil {
+		afterJSON = []byte("{}")
+	}
+
+	patch, err := jsonpatch.CreateMergePatch(beforeJSON, afterJSON)
+	if err != nil {
+		return nil
+	}
+
+	return patch
+}
+
+// historyEvents returns the names of the events, prefixed with the policy template name, in the history of the new
+// status but not of the old status.
+func historyEvents(oldStatus policiesv1.PolicyStatus, newStatus policiesv1.PolicyStatus) []string {
+	known := map[string]bool{}
+
+	for _, dpt := range oldStatus.Details {
+		if dpt == nil {
+			continue
+		}
+
+		for _, entry := range dpt.History {
+			known[dpt.TemplateMeta.Name+"/"+entry.EventName] = true
+		}
+	}
+
+	events := []string{}
+
+	for _, dpt := range newStatus.Details {
+		if dpt == nil {
+			continue
+		}
+
+		for _, entry := range dpt.History {
+			name := dpt.TemplateMeta.Name + "/" + entry.EventName
+			if !known[name] {
+				events = append(events, name)
+				known[name] = true
+			}
+		}
+	}
+
+	return events
+}
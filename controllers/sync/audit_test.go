@@ -0,0 +1,141 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+)
+
+func TestAuditLogRecord(t *testing.T) {
+	t.Parallel()
+
+	out := &bytes.Buffer{}
+	auditLog := NewAuditLogWriter(out)
+
+	auditLog.Record(AuditRecord{
+		Action:    AuditHubStatusUpdated,
+		Namespace: "managed",
+		Policy:    "policy",
+		Events:    []string{"template/policy.1234"},
+		Before:    policiesv1.PolicyStatus{ComplianceState: policiesv1.Compliant},
+		After:     policiesv1.PolicyStatus{ComplianceState: policiesv1.NonCompliant},
+	})
+
+	record := map[string]interface{}{}
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatalf("the audit record isn't a JSON line: %v", err)
+	}
+
+	if record["action"] != AuditHubStatusUpdated || record["time"] == "" {
+		t.Fatalf("unexpected audit record: %v", record)
+	}
+
+	diff, _ := json.Marshal(record["diff"])
+	if string(diff) != `{"compliant":"NonCompliant"}` {
+		t.Fatalf("unexpected diff: %s", diff)
+	}
+}
+
+func TestAuditLogRotation(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	auditLog, err := NewAuditLog(path, 300, 2)
+	if err != nil {
+		t.Fatalf("failed to open the audit log: %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		auditLog.Record(AuditRecord{Action: AuditManagedStatusUpdated, Reason: strings.Repeat("x", 100)})
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatalf("expected the audit log file %s: %v", name, err)
+		}
+
+		if info.Size() > 300 {
+			t.Fatalf("expected %s to be rotated before exceeding the maximum size, got %d bytes", name, info.Size())
+		}
+	}
+
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatal("expected only two backups to be kept")
+	}
+}
+
+func TestAuditLogRotationCloseFailure(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	auditLog, err := NewAuditLog(path, 300, 1)
+	if err != nil {
+		t.Fatalf("failed to open the audit log: %v", err)
+	}
+
+	record := AuditRecord{Action: AuditManagedStatusUpdated, Reason: strings.Repeat("x", 100)}
+	auditLog.Record(record)
+
+	// the file can't be closed again, which must not prevent the rotation
+	auditLog.file.Close()
+
+	auditLog.Record(record)
+	auditLog.Record(record)
+
+	// each record exceeds the maximum size with the previous one, so the file and its backup have the last records
+	for _, name := range []string{path, path + ".1"} {
+		content, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("expected the audit log file %s: %v", name, err)
+		}
+
+		if lines := strings.Count(string(content), "\n"); lines != 1 {
+			t.Fatalf("expected a record in %s after the rotation, got %d records", name, lines)
+		}
+	}
+}
+
+func TestAuditLogClose(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	auditLog, err := NewAuditLog(path, 0, 0)
+	if err != nil {
+		t.Fatalf("failed to open the audit log: %v", err)
+	}
+
+	record := AuditRecord{Action: AuditManagedStatusUpdated, Reason: "The status changed"}
+	auditLog.Record(record)
+
+	if err := auditLog.Close(); err != nil {
+		t.Fatalf("failed to close the audit log: %v", err)
+	}
+
+	// the records after closing are discarded instead of failing to be written to the closed file
+	auditLog.Record(record)
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read the audit log: %v", err)
+	}
+
+	if lines := strings.Count(string(content), "\n"); lines != 1 {
+		t.Fatalf("expected only the record before closing, got %d records", lines)
+	}
+
+	var nilAuditLog *AuditLog
+	if err := nilAuditLog.Close(); err != nil {
+		t.Fatalf("expected closing a nil audit log to do nothing, got %v", err)
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+// ComplianceChangeEventType is the CloudEvents type of the compliance state changes sent by CloudEventsSink
+const ComplianceChangeEventType = "io.open-cluster-management.policy.compliance.changed"
+
+// CloudEventsSinkOptions configure a CloudEventsSink.
+type CloudEventsSinkOptions struct {
+	// Endpoints are the URLs to which every compliance state change is posted
+	Endpoints []string
+	// Source is the CloudEvents source of the events, such as the name of the managed cluster
+	Source string
+	// QueueSize is the number of changes waiting to be sent after which new changes are dropped
+	QueueSize int
+	// MaxRetries is the number of times a failed request is retried before the change is dropped for the endpoint
+	MaxRetries int
+	// RetryDelay is the delay before the first retry, which doubles on every retry
+	RetryDelay time.Duration
+	// Timeout is the timeout of each request
+	Timeout time.Duration
+	// CAFile is the PEM file of the certificate authorities trusted in addition to the system ones
+	CAFile string
+	// CertFile and KeyFile are the PEM files of the client certificate and key, for mutual TLS
+	CertFile string
+	KeyFile  string
+	// InsecureSkipVerify disables the verification of the certificates of the endpoints
+	InsecureSkipVerify bool
+}
+
+// cloudEvent is a CloudEvent in the structured JSON content mode.
+type cloudEvent struct {
+	SpecVersion     string           `json:"specversion"`
+	ID              string           `json:"id"`
+	Source          string           `json:"source"`
+	Type            string           `json:"type"`
+	Subject         string           `json:"subject"`
+	Time            time.Time        `json:"time"`
+	DataContentType string           `json:"datacontenttype"`
+	Data            ComplianceChange `json:"data"`
+}
+
+// CloudEventsSink is a NotificationSink which posts each compliance state change as a CloudEvent to HTTP endpoints.
+// The changes are queued and sent in order by Start, so Notify never blocks the reconcile.
+type CloudEventsSink struct {
+	options CloudEventsSinkOptions
+	client  *http.Client
+	queue   chan ComplianceChange
+}
+
+// NewCloudEventsSink returns a CloudEventsSink with an HTTP client configured from the input options.
+func NewCloudEventsSink(options CloudEventsSinkOptions) (*CloudEventsSink, error) {
+	if len(options.Endpoints) == 0 {
+		return nil, errors.New("at least one endpoint is required")
+	}
+
+	if options.QueueSize <= 0 {
+		return nil, fmt.Errorf("the queue size must be positive, got %d", options.QueueSize)
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: options.InsecureSkipVerify, //nolint:gosec
+	}
+
+	if options.CAFile != "" {
+		caPEM, err := os.ReadFile(options.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificate found in %s", options.CAFile)
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if options.CertFile != "" || options.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(options.CertFile, options.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &CloudEventsSink{
+		options: options,
+		client:  &http.Client{Transport: transport, Timeout: options.Timeout},
+		queue:   make(chan ComplianceChange, options.QueueSize),
+	}, nil
+}
+
+// Notify queues the input change, or drops it if the queue is full.
+func (s *CloudEventsSink) Notify(change ComplianceChange) {
+	select {
+	case s.queue <- change:
+	default:
+		log.Info("The notification queue is full, dropping the compliance change",
+			"Request.Namespace", change.Namespace, "Request.Name", change.Policy, "template", change.Template)
+		notificationsCounter.WithLabelValues("dropped").Inc()
+	}
+}
+
+// Start sends the queued changes until the input context is done. It implements the manager.Runnable interface.
+func (s *CloudEventsSink) Start(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case change := <-s.queue:
+			for _, endpoint := range s.options.Endpoints {
+				if err := s.send(ctx, endpoint, change); err != nil {
+					log.Error(err, "Failed to send the compliance change notification", "endpoint", endpoint,
+						"Request.Namespace", change.Namespace, "Request.Name", change.Policy,
+						"template", change.Template)
+					notificationsCounter.WithLabelValues("failed").Inc()
+
+					continue
+				}
+
+				notificationsCounter.WithLabelValues("sent").Inc()
+			}
+		}
+	}
+}
+
+// NeedLeaderElection returns false since only the reconciles, which run on the leader, queue changes.
+func (s *CloudEventsSink) NeedLeaderElection() bool {
+	return false
+}
+
+// send posts the input change to the input endpoint, retrying on connection errors and on 429 and 5xx responses.
+func (s *CloudEventsSink) send(ctx context.Context, endpoint string, change ComplianceChange) error {
+	subject := change.Namespace + "/" + change.Policy
+	if change.Template != "" {
+		subject += "/" + change.Template
+	}
+
+	body, err := json.Marshal(cloudEvent{
+		SpecVersion:     "1.0",
+		ID:              string(uuid.NewUUID()),
+		Source:          s.options.Source,
+		Type:            ComplianceChangeEventType,
+		Subject:         subject,
+		Time:            change.Time,
+		DataContentType: "application/json",
+		Data:            change,
+	})
+	if err != nil {
+		return err
+	}
+
+	delay := s.options.RetryDelay
+
+	for attempt := 0; ; attempt++ {
+		retry, err := s.post(ctx, endpoint, body)
+		if err == nil {
+			return nil
+		}
+
+		if !retry || attempt >= s.options.MaxRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+	}
+}
+
+// post posts the input CloudEvent once. It returns whether a failed request can be retried.
+func (s *CloudEventsSink) post(ctx context.Context, endpoint string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("Content-Type", "application/cloudevents+json; charset=UTF-8")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+
+	defer resp.Body.Close()
+
+	// drain the body so the connection is reused
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	err = fmt.Errorf("the endpoint responded with the status %s", resp.Status)
+
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
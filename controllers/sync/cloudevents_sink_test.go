@@ -0,0 +1,158 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+)
+
+// startSink starts a CloudEventsSink posting to the input server and returns it with the function stopping it.
+func startSink(
+	t *testing.T, server *httptest.Server, options CloudEventsSinkOptions,
+) (*CloudEventsSink, context.CancelFunc) {
+	t.Helper()
+
+	options.Endpoints = []string{server.URL}
+	options.Source = "managed"
+	options.QueueSize = 10
+	options.RetryDelay = time.Millisecond
+	options.Timeout = 5 * time.Second
+
+	sink, err := NewCloudEventsSink(options)
+	if err != nil {
+		t.Fatalf("failed to create the sink: %v", err)
+	}
+
+	if server.TLS != nil {
+		// trust the certificate of the test server
+		sink.client.Transport = server.Client().Transport
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		_ = sink.Start(ctx)
+	}()
+
+	return sink, cancel
+}
+
+func TestCloudEventsSink(t *testing.T) {
+	t.Parallel()
+
+	received := make(chan cloudEvent, 1)
+	attempts := 0
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			// the first attempt fails and must be retried
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		if r.Header.Get("Content-Type") != "application/cloudevents+json; charset=UTF-8" {
+			t.Errorf("unexpected content type %s", r.Header.Get("Content-Type"))
+		}
+
+		event := cloudEvent{}
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("failed to decode the event: %v", err)
+		}
+
+		received <- event
+	}))
+	defer server.Close()
+
+	sink, cancel := startSink(t, server, CloudEventsSinkOptions{MaxRetries: 2})
+	defer cancel()
+
+	sink.Notify(ComplianceChange{
+		Namespace:     "managed",
+		Policy:        "policy",
+		Template:      "template",
+		PreviousState: policiesv1.Compliant,
+		State:         policiesv1.NonCompliant,
+		Message:       "NonCompliant; violation - missing",
+		Time:          time.Now(),
+	})
+
+	select {
+	case event := <-received:
+		if event.SpecVersion != "1.0" || event.Type != ComplianceChangeEventType || event.Source != "managed" ||
+			event.Subject != "managed/policy/template" || event.ID == "" {
+			t.Fatalf("unexpected CloudEvent attributes: %+v", event)
+		}
+
+		if event.Data.State != policiesv1.NonCompliant || event.Data.PreviousState != policiesv1.Compliant {
+			t.Fatalf("unexpected CloudEvent data: %+v", event.Data)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("the CloudEvent was not received")
+	}
+}
+
+func TestCloudEventsSinkQueueFull(t *testing.T) {
+	t.Parallel()
+
+	sink, err := NewCloudEventsSink(CloudEventsSinkOptions{Endpoints: []string{"http://localhost"}, QueueSize: 1})
+	if err != nil {
+		t.Fatalf("failed to create the sink: %v", err)
+	}
+
+	// the sink isn't started, so the second change doesn't fit in the queue and must not block
+	sink.Notify(ComplianceChange{Policy: "first"})
+	sink.Notify(ComplianceChange{Policy: "second"})
+
+	if len(sink.queue) != 1 || (<-sink.queue).Policy != "first" {
+		t.Fatal("expected only the first change to be queued")
+	}
+}
+
+func TestComplianceStateChanges(t *testing.T) {
+	t.Parallel()
+
+	instance := &policiesv1.Policy{}
+	instance.SetName("policy")
+	instance.SetNamespace("managed")
+
+	detail := func(name string, state policiesv1.ComplianceState) *policiesv1.DetailsPerTemplate {
+		dpt := &policiesv1.DetailsPerTemplate{ComplianceState: state}
+		dpt.TemplateMeta.SetName(name)
+
+		return dpt
+	}
+
+	oldStatus := policiesv1.PolicyStatus{
+		ComplianceState: policiesv1.Compliant,
+		Details: []*policiesv1.DetailsPerTemplate{
+			detail("a", policiesv1.Compliant), detail("b", policiesv1.Compliant),
+		},
+	}
+	newStatus := policiesv1.PolicyStatus{
+		ComplianceState: policiesv1.NonCompliant,
+		Details: []*policiesv1.DetailsPerTemplate{
+			detail("a", policiesv1.Compliant),
+			detail("b", policiesv1.NonCompliant),
+			detail("c", policiesv1.Compliant),
+		},
+	}
+
+	changes := complianceStateChanges(instance, oldStatus, newStatus)
+	if len(changes) != 3 {
+		t.Fatalf("expected three changes, got %+v", changes)
+	}
+
+	if changes[0].Template != "b" || changes[1].Template != "c" || changes[1].PreviousState != "" ||
+		changes[2].Template != "" || changes[2].State != policiesv1.NonCompliant {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+}
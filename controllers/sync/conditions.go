@@ -0,0 +1,374 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	gosync "sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ConditionsConfigMapSuffix is the suffix of the name of the ConfigMap, in the namespace of the managed policy, in
+// which the controller maintains the conditions of the policy. The Policy CRD doesn't have a conditions field in its
+// status, and the spec sync replaces the annotations of the managed policy with those of the hub policy, so they are
+// kept in a ConfigMap owned by the policy, which is deleted with it.
+const ConditionsConfigMapSuffix = ".status-sync"
+
+// Keys of the data of the conditions ConfigMap
+const (
+	// ConditionsKey holds the conditions of the policy as a JSON list
+	ConditionsKey = "conditions"
+	// LastSyncTimeKey holds the time, in RFC 3339, of the last successful sync of the status to the hub
+	LastSyncTimeKey = "lastSyncTime"
+	// LastErrorKey holds the last error of a sync of the policy
+	LastErrorKey = "lastError"
+	// LastErrorTimeKey holds the time, in RFC 3339, of the last error of a sync of the policy
+	LastErrorTimeKey = "lastErrorTime"
+)
+
+// conditionsWriteInterval is the minimum interval between the writes of the conditions ConfigMap of a policy that
+// only update the last sync time or the time of the same error
+const conditionsWriteInterval = time.Minute
+
+// Condition types maintained on the managed policy
+const (
+	// ConditionSpecInSync is true when the spec, labels and annotations of the managed policy match the hub policy.
+	// Its reason tells whether the managed policy was recreated or reverted from the hub policy.
+	ConditionSpecInSync = "SpecInSync"
+	// ConditionSynced is true when the status of the policy was last synced to the hub successfully. Its message
+	// contains the error of the sync that failed when it became false. The time of the last successful sync and the
+	// last error are kept in the LastSyncTimeKey and LastErrorKey keys of the conditions ConfigMap.
+	ConditionSynced = "Synced"
+	// ConditionHubReachable is true when the policy was last retrieved from the hub successfully
+	ConditionHubReachable = "HubReachable"
+)
+
+// ConditionsConfigMapName returns the name of the conditions ConfigMap of the input policy.
+func ConditionsConfigMapName(policyName string) string {
+	return policyName + ConditionsConfigMapSuffix
+}
+
+// syncRecord is the content of the conditions ConfigMap of a policy.
+type syncRecord struct {
+	conditions    []metav1.Condition
+	lastSyncTime  time.Time
+	lastError     string
+	lastErrorTime time.Time
+}
+
+// data returns the data of the conditions ConfigMap with the record.
+func (s syncRecord) data() (map[string]string, error) {
+	conditions, err := json.Marshal(s.conditions)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]string{ConditionsKey: string(conditions)}
+
+	if !s.lastSyncTime.IsZero() {
+		data[LastSyncTimeKey] = s.lastSyncTime.UTC().Format(time.RFC3339)
+	}
+
+	if s.lastError != "" {
+		data[LastErrorKey] = s.lastError
+		data[LastErrorTimeKey] = s.lastErrorTime.UTC().Format(time.RFC3339)
+	}
+
+	return data, nil
+}
+
+// parseSyncRecord returns the record in the data of a conditions ConfigMap. Invalid content is ignored.
+func parseSyncRecord(data map[string]string) syncRecord {
+	record := syncRecord{conditions: []metav1.Condition{}}
+
+	if raw, found := data[ConditionsKey]; found {
+		if err := json.Unmarshal([]byte(raw), &record.conditions); err != nil {
+			log.Error(err, "Ignoring the invalid conditions in the conditions ConfigMap")
+
+			record.conditions = []metav1.Condition{}
+		}
+	}
+
+	record.lastSyncTime, _ = time.Parse(time.RFC3339, data[LastSyncTimeKey])
+	record.lastError = data[LastErrorKey]
+	record.lastErrorTime, _ = time.Parse(time.RFC3339, data[LastErrorTimeKey])
+
+	return record
+}
+
+// trackedConditions is the record of a policy and what is stored in its conditions ConfigMap.
+type trackedConditions struct {
+	record syncRecord
+	// stored is the record last read from or written to the ConfigMap, and storedAt when
+	stored   syncRecord
+	storedAt time.Time
+	exists   bool
+}
+
+// conditionTracker caches the conditions ConfigMap of each policy, which is read on the first use of the policy.
+type conditionTracker struct {
+	lock     gosync.Mutex
+	policies map[types.NamespacedName]*trackedConditions
+}
+
+// get returns the tracked conditions of the input policy, or nil if they weren't read yet.
+func (t *conditionTracker) get(key types.NamespacedName) *trackedConditions {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.policies[key]
+}
+
+// set records the tracked conditions of the input policy.
+func (t *conditionTracker) set(key types.NamespacedName, tracked *trackedConditions) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.policies == nil {
+		t.policies = map[types.NamespacedName]*trackedConditions{}
+	}
+
+	t.policies[key] = tracked
+}
+
+// forget stops tracking the input policy, because it was deleted. Its ConfigMap is deleted with it.
+func (t *conditionTracker) forget(key types.NamespacedName) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	delete(t.policies, key)
+}
+
+// setConditions returns the input existing conditions updated with the input conditions, with the input observed
+// generation. A condition is only updated when its status or reason changes, so that a new message, such as another
+// error, doesn't count as a change. It returns whether the conditions changed.
+func setConditions(
+	existing []metav1.Condition, generation int64, conditions ...metav1.Condition,
+) ([]metav1.Condition, bool) {
+	updated := make([]metav1.Condition, len(existing))
+	copy(updated, existing)
+
+	for _, condition := range conditions {
+		current := meta.FindStatusCondition(updated, condition.Type)
+		if current != nil && current.Status == condition.Status && current.Reason == condition.Reason {
+			continue
+		}
+
+		condition.ObservedGeneration = generation
+		meta.SetStatusCondition(&updated, condition)
+	}
+
+	return updated, !equality.Semantic.DeepEqual(updated, existing)
+}
+
+// isFailure returns whether the input condition reports an error, which is when it is false with a reason ending
+// with "Failed", such as "HubRequestFailed" or "HubStatusUpdateFailed".
+func isFailure(condition metav1.Condition) bool {
+	return condition.Status == metav1.ConditionFalse && strings.HasSuffix(condition.Reason, "Failed")
+}
+
+// trackConditions returns the tracked conditions of the input managed policy, reading its conditions ConfigMap the
+// first time.
+func (r *PolicyReconciler) trackConditions(ctx context.Context, plc *policiesv1.Policy) (*trackedConditions, error) {
+	key := client.ObjectKeyFromObject(plc)
+	if tracked := r.conditionStore.get(key); tracked != nil {
+		return tracked, nil
+	}
+
+	reader := r.ManagedReader
+	if reader == nil {
+		reader = r.ManagedClient
+	}
+
+	configMap := &corev1.ConfigMap{}
+	tracked := &trackedConditions{record: syncRecord{conditions: []metav1.Condition{}}}
+
+	err := reader.Get(ctx, types.NamespacedName{
+		Namespace: plc.GetNamespace(), Name: ConditionsConfigMapName(plc.GetName()),
+	}, configMap)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get the conditions ConfigMap: %w", err)
+	}
+
+	if err == nil {
+		tracked.record = parseSyncRecord(configMap.Data)
+		tracked.stored = tracked.record
+		tracked.exists = true
+	}
+
+	r.conditionStore.set(key, tracked)
+
+	return tracked, nil
+}
+
+// conditions returns the conditions of the input managed policy.
+func (r *PolicyReconciler) conditions(ctx context.Context, plc *policiesv1.Policy) ([]metav1.Condition, error) {
+	tracked, err := r.trackConditions(ctx, plc)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]metav1.Condition{}, tracked.record.conditions...), nil
+}
+
+// patchConditions sets the input conditions of the managed policy, and records the time of a successful sync to the
+// hub or the error of a failed one, in the conditions ConfigMap of the policy. The ConfigMap is written when a
+// condition or the error changes, and otherwise at most every conditionsWriteInterval, so the last sync time is
+// kept up to date without a write on every reconcile. The managed policy itself is never modified.
+func (r *PolicyReconciler) patchConditions(
+	ctx context.Context, plc *policiesv1.Policy, conditions ...metav1.Condition,
+) error {
+	key := client.ObjectKeyFromObject(plc)
+	r.syncStates.observeConditions(key, conditions)
+
+	tracked, err := r.trackConditions(ctx, plc)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	record := tracked.record
+
+	var changed bool
+
+	record.conditions, changed = setConditions(record.conditions, plc.GetGeneration(), conditions...)
+
+	for _, condition := range conditions {
+		if isFailure(condition) {
+			record.lastError = condition.Message
+			record.lastErrorTime = now
+		} else if condition.Type == ConditionSynced && condition.Status == metav1.ConditionTrue {
+			record.lastSyncTime = now
+		}
+	}
+
+	tracked.record = record
+
+	timesChanged := !record.lastSyncTime.Equal(tracked.stored.lastSyncTime) ||
+		!record.lastErrorTime.Equal(tracked.stored.lastErrorTime)
+	if !changed && record.lastError == tracked.stored.lastError && tracked.exists &&
+		(!timesChanged || now.Sub(tracked.storedAt) < conditionsWriteInterval) {
+		return nil
+	}
+
+	if err := r.writeConditions(ctx, plc, tracked); err != nil {
+		return err
+	}
+
+	if changed {
+		r.audit(plc, AuditRecord{
+			Action: AuditManagedConditionsUpdated,
+			Reason: "The sync conditions changed",
+			Before: tracked.stored.conditions,
+			After:  record.conditions,
+		})
+	}
+
+	tracked.stored = record
+	tracked.storedAt = now
+	tracked.exists = true
+
+	return nil
+}
+
+// writeConditions creates or updates the conditions ConfigMap of the input policy with its tracked record.
+func (r *PolicyReconciler) writeConditions(
+	ctx context.Context, plc *policiesv1.Policy, tracked *trackedConditions,
+) error {
+	data, err := tracked.record.data()
+	if err != nil {
+		return err
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ConditionsConfigMapName(plc.GetName()),
+			Namespace: plc.GetNamespace(),
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(plc, policiesv1.GroupVersion.WithKind(policiesv1.Kind)),
+			},
+		},
+		Data: data,
+	}
+
+	if tracked.exists {
+		err = r.ManagedClient.Update(ctx, configMap)
+		if errors.IsNotFound(err) {
+			err = r.ManagedClient.Create(ctx, configMap)
+		}
+	} else {
+		err = r.ManagedClient.Create(ctx, configMap)
+		if errors.IsAlreadyExists(err) {
+			err = r.ManagedClient.Update(ctx, configMap)
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to write the conditions ConfigMap: %w", err)
+	}
+
+	return nil
+}
+
+// reportFailure sets the input conditions on the managed policy while the reconcile is already failing, so an error
+// setting them is only logged.
+func (r *PolicyReconciler) reportFailure(
+	ctx context.Context, plc *policiesv1.Policy, conditions ...metav1.Condition,
+) {
+	if err := r.patchConditions(ctx, plc, conditions...); err != nil {
+		log.Error(err, "Failed to set the conditions on the managed policy",
+			"Request.Namespace", plc.GetNamespace(), "Request.Name", plc.GetName())
+	}
+}
+
+// syncedCondition returns the condition of the last sync of the status to the hub. A nil error means the sync
+// succeeded.
+func syncedCondition(reason string, message string, err error) metav1.Condition {
+	if err != nil {
+		return metav1.Condition{
+			Type:    ConditionSynced,
+			Status:  metav1.ConditionFalse,
+			Reason:  reason,
+			Message: err.Error(),
+		}
+	}
+
+	return metav1.Condition{
+		Type:    ConditionSynced,
+		Status:  metav1.ConditionTrue,
+		Reason:  reason,
+		Message: message,
+	}
+}
+
+// hubReachableCondition returns the condition of the last retrieval of the policy from the hub. A nil error means
+// the retrieval succeeded.
+func hubReachableCondition(err error) metav1.Condition {
+	if err != nil {
+		return metav1.Condition{
+			Type:    ConditionHubReachable,
+			Status:  metav1.ConditionFalse,
+			Reason:  "HubRequestFailed",
+			Message: err.Error(),
+		}
+	}
+
+	return metav1.Condition{
+		Type:    ConditionHubReachable,
+		Status:  metav1.ConditionTrue,
+		Reason:  "HubRequestSucceeded",
+		Message: "The policy was retrieved from the hub",
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+	"open-cluster-management.io/governance-policy-propagator/controllers/common"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestSetConditions(t *testing.T) {
+	t.Parallel()
+
+	conditions := []metav1.Condition{}
+
+	steps := []struct {
+		condition metav1.Condition
+		changed   bool
+	}{
+		{hubReachableCondition(nil), true},
+		{hubReachableCondition(nil), false},
+		{hubReachableCondition(errors.New("connection refused")), true},
+		// only the message differs, which must not count as a change
+		{hubReachableCondition(errors.New("i/o timeout")), false},
+		{syncedCondition("HubStatusUpdated", "The status was synced to the hub", nil), true},
+		{syncedCondition("HubStatusMatched", "The status on the hub matches the managed policy", nil), true},
+	}
+
+	for i, step := range steps {
+		var changed bool
+
+		conditions, changed = setConditions(conditions, 1, step.condition)
+		if changed != step.changed {
+			t.Fatalf("step %d: expected the conditions to change: %v, got %v", i, step.changed, changed)
+		}
+	}
+
+	if len(conditions) != 2 {
+		t.Fatalf("expected 2 conditions, got %+v", conditions)
+	}
+
+	hubReachable := meta.FindStatusCondition(conditions, ConditionHubReachable)
+	if hubReachable.Message != "connection refused" || hubReachable.ObservedGeneration != 1 {
+		t.Fatalf("expected the message of the last status change, got %+v", hubReachable)
+	}
+
+	if synced := meta.FindStatusCondition(conditions, ConditionSynced); synced.Reason != "HubStatusMatched" {
+		t.Fatalf("expected the reason of the last sync, got %+v", synced)
+	}
+}
+
+// conditionsTestReconciler returns a reconciler with a fake managed client containing the input managed policy.
+func conditionsTestReconciler(t *testing.T, instance *policiesv1.Policy) *PolicyReconciler {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	if err := policiesv1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build the scheme: %v", err)
+	}
+
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build the scheme: %v", err)
+	}
+
+	r := &PolicyReconciler{
+		ManagedClient: fake.NewClientBuilder().WithScheme(scheme).WithObjects(instance.DeepCopy()).Build(),
+	}
+
+	if err := r.ManagedClient.Get(context.TODO(), client.ObjectKeyFromObject(instance), instance); err != nil {
+		t.Fatalf("failed to get the policy: %v", err)
+	}
+
+	return r
+}
+
+// conditionsConfigMap returns the conditions ConfigMap of the input policy, or nil if it doesn't exist.
+func conditionsConfigMap(t *testing.T, r *PolicyReconciler, instance *policiesv1.Policy) *corev1.ConfigMap {
+	t.Helper()
+
+	configMap := &corev1.ConfigMap{}
+
+	err := r.ManagedClient.Get(context.TODO(), types.NamespacedName{
+		Namespace: instance.GetNamespace(), Name: ConditionsConfigMapName(instance.GetName()),
+	}, configMap)
+	if err != nil {
+		return nil
+	}
+
+	return configMap
+}
+
+func TestPatchConditions(t *testing.T) {
+	t.Parallel()
+
+	instance := &policiesv1.Policy{ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "managed"}}
+	r := conditionsTestReconciler(t, instance)
+	key := client.ObjectKeyFromObject(instance)
+
+	steps := []struct {
+		description string
+		condition   metav1.Condition
+		// elapsed is added to the time since the last write of the ConfigMap before the step
+		elapsed   time.Duration
+		written   bool
+		lastError string
+	}{
+		{
+			description: "first sync",
+			condition:   syncedCondition("HubStatusUpdated", "The status was synced to the hub", nil),
+			written:     true,
+		},
+		{
+			description: "same sync within the write interval",
+			condition:   syncedCondition("HubStatusUpdated", "The status was synced to the hub", nil),
+		},
+		{
+			description: "same sync after the write interval",
+			condition:   syncedCondition("HubStatusUpdated", "The status was synced to the hub", nil),
+			elapsed:     conditionsWriteInterval,
+			written:     true,
+		},
+		{
+			description: "failed sync",
+			condition:   syncedCondition("HubStatusUpdateFailed", "", errors.New("conflict")),
+			written:     true,
+			lastError:   "conflict",
+		},
+		{
+			description: "other error",
+			condition:   syncedCondition("HubStatusUpdateFailed", "", errors.New("forbidden")),
+			written:     true,
+			lastError:   "forbidden",
+		},
+		{
+			description: "same error within the write interval",
+			condition:   syncedCondition("HubStatusUpdateFailed", "", errors.New("forbidden")),
+			lastError:   "forbidden",
+		},
+		{
+			description: "recovered sync",
+			condition:   syncedCondition("HubStatusUpdated", "The status was synced to the hub", nil),
+			written:     true,
+			lastError:   "forbidden",
+		},
+	}
+
+	for _, step := range steps {
+		if tracked := r.conditionStore.get(key); tracked != nil {
+			tracked.storedAt = tracked.storedAt.Add(-step.elapsed)
+		}
+
+		resourceVersion := ""
+		if configMap := conditionsConfigMap(t, r, instance); configMap != nil {
+			resourceVersion = configMap.GetResourceVersion()
+		}
+
+		if err := r.patchConditions(context.TODO(), instance, step.condition); err != nil {
+			t.Fatalf("%s: failed to patch the conditions: %v", step.description, err)
+		}
+
+		configMap := conditionsConfigMap(t, r, instance)
+		if configMap == nil {
+			t.Fatalf("%s: expected the conditions ConfigMap", step.description)
+		}
+
+		if written := configMap.GetResourceVersion() != resourceVersion; written != step.written {
+			t.Fatalf("%s: expected the ConfigMap to be written: %v, got %v", step.description, step.written, written)
+		}
+
+		record := parseSyncRecord(configMap.Data)
+		if record.lastError != step.lastError || record.lastSyncTime.IsZero() {
+			t.Fatalf("%s: expected the last error %q and a last sync time, got %+v", step.description,
+				step.lastError, configMap.Data)
+		}
+
+		synced := meta.FindStatusCondition(record.conditions, ConditionSynced)
+		if synced == nil || synced.Reason != step.condition.Reason {
+			t.Fatalf("%s: expected the Synced condition %+v, got %+v", step.description, step.condition, synced)
+		}
+
+		owners := configMap.GetOwnerReferences()
+		if len(owners) != 1 || owners[0].Kind != policiesv1.Kind || owners[0].Name != instance.GetName() {
+			t.Fatalf("%s: expected the ConfigMap to be owned by the policy, got %+v", step.description, owners)
+		}
+	}
+
+	// a reconciler starting with the same cluster, such as after a restart, reads the conditions back
+	restarted := &PolicyReconciler{ManagedClient: r.ManagedClient}
+
+	conditions, err := restarted.conditions(context.TODO(), instance)
+	if err != nil {
+		t.Fatalf("failed to get the conditions: %v", err)
+	}
+
+	if !meta.IsStatusConditionTrue(conditions, ConditionSynced) {
+		t.Fatalf("expected the Synced condition to be read from the ConfigMap, got %+v", conditions)
+	}
+}
+
+func TestPatchConditionsKeepsPolicyInSync(t *testing.T) {
+	t.Parallel()
+
+	hubPlc := &policiesv1.Policy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "policy",
+			Namespace:   "cluster",
+			Annotations: map[string]string{"policy.open-cluster-management.io/standards": "NIST SP 800-53"},
+		},
+		Spec: policiesv1.PolicySpec{RemediationAction: policiesv1.Inform},
+	}
+	instance := hubPlc.DeepCopy()
+	instance.Namespace = "managed"
+	r := conditionsTestReconciler(t, instance)
+
+	err := r.patchConditions(context.TODO(), instance,
+		hubReachableCondition(nil), syncedCondition("HubStatusUpdated", "The status was synced to the hub", nil))
+	if err != nil {
+		t.Fatalf("failed to patch the conditions: %v", err)
+	}
+
+	managedPlc := &policiesv1.Policy{}
+	if err := r.ManagedClient.Get(context.TODO(), client.ObjectKeyFromObject(instance), managedPlc); err != nil {
+		t.Fatalf("failed to get the policy: %v", err)
+	}
+
+	// the spec sync compares the spec and all the annotations, so it must not see a difference to revert
+	if !common.CompareSpecAndAnnotation(managedPlc, hubPlc) {
+		t.Fatalf("expected the managed policy to still match the hub policy, got the annotations %v",
+			managedPlc.GetAnnotations())
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// DebugPoliciesPath is the path of the debug endpoint listing the sync state of the policies. The sync state of a
+// single policy is served at DebugPoliciesPath/<namespace>/<name>.
+const DebugPoliciesPath = "/debug/policies"
+
+// Outcomes of a reconcile in the sync state of a policy
+const (
+	ReconcileSucceeded = "Succeeded"
+	ReconcileFailed    = "Failed"
+)
+
+// PolicySyncState is the sync state of a policy as of its last reconcile, served by the debug endpoint.
+type PolicySyncState struct {
+	Namespace            string    `json:"namespace"`
+	Name                 string    `json:"name"`
+	LastReconcileTime    time.Time `json:"lastReconcileTime"`
+	LastReconcileOutcome string    `json:"lastReconcileOutcome,omitempty"`
+	// RequeueAfter is the delay after which the policy is reconciled again, regardless of events
+	RequeueAfter string `json:"requeueAfter,omitempty"`
+	// LastHubWriteTime is when the status was last written to the hub since the controller started
+	LastHubWriteTime *time.Time `json:"lastHubWriteTime,omitempty"`
+	LastError        string     `json:"lastError,omitempty"`
+	LastErrorTime    *time.Time `json:"lastErrorTime,omitempty"`
+	// EventCount is the number of events of the policy considered by the last status computation
+	EventCount int                 `json:"eventCount"`
+	Templates  []TemplateSyncState `json:"templates,omitempty"`
+	// Conditions are the conditions last set on the policy
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// TemplateSyncState is the computed state of a policy template, served by the debug endpoint.
+type TemplateSyncState struct {
+	Name               string                     `json:"name"`
+	ComplianceState    policiesv1.ComplianceState `json:"compliant,omitempty"`
+	LastTransitionTime *time.Time                 `json:"lastTransitionTime,omitempty"`
+	HistoryLength      int                        `json:"historyLength"`
+}
+
+// syncStateTracker records the sync state of each policy reconciled since the controller started.
+type syncStateTracker struct {
+	lock   gosync.Mutex
+	states map[types.NamespacedName]*PolicySyncState
+}
+
+// start records that a reconcile of the input policy started.
+func (t *syncStateTracker) start(key types.NamespacedName) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.states == nil {
+		t.states = map[types.NamespacedName]*PolicySyncState{}
+	}
+
+	state := t.states[key]
+	if state == nil {
+		state = &PolicySyncState{Namespace: key.Namespace, Name: key.Name}
+		t.states[key] = state
+	}
+
+	state.LastReconcileTime = time.Now().UTC()
+}
+
+// observeStatus records the number of events considered and the computed status of the input policy.
+func (t *syncStateTracker) observeStatus(key types.NamespacedName, eventCount int, status policiesv1.PolicyStatus) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	state := t.states[key]
+	if state == nil {
+		return
+	}
+
+	state.EventCount = eventCount
+	state.Templates = []TemplateSyncState{}
+
+	for _, dpt := range status.Details {
+		if dpt == nil {
+			continue
+		}
+
+		template := TemplateSyncState{
+			Name:            dpt.TemplateMeta.Name,
+			ComplianceState: dpt.ComplianceState,
+			HistoryLength:   len(dpt.History),
+		}
+
+		if transitionTime, found := templateTransitionTime(dpt); found {
+			template.LastTransitionTime = &transitionTime
+		}
+
+		state.Templates = append(state.Templates, template)
+	}
+}
+
+// observeConditions records the conditions set on the input policy.
+func (t *syncStateTracker) observeConditions(key types.NamespacedName, conditions []metav1.Condition) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	state := t.states[key]
+	if state == nil {
+		return
+	}
+
+	for _, condition := range conditions {
+		meta.SetStatusCondition(&state.Conditions, condition)
+	}
+}
+
+// finish records the outcome of the reconcile of the input policy, unless the policy was forgotten during the
+// reconcile because it was deleted.
+func (t *syncStateTracker) finish(key types.NamespacedName, result reconcile.Result, err error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	state := t.states[key]
+	if state == nil {
+		return
+	}
+
+	state.RequeueAfter = ""
+	if result.RequeueAfter > 0 {
+		state.RequeueAfter = result.RequeueAfter.String()
+	}
+
+	if err == nil {
+		state.LastReconcileOutcome = ReconcileSucceeded
+
+		return
+	}
+
+	now := time.Now().UTC()
+	state.LastReconcileOutcome = ReconcileFailed
+	state.LastError = err.Error()
+	state.LastErrorTime = &now
+}
+
+// forget stops tracking the input policy, because it was deleted.
+func (t *syncStateTracker) forget(key types.NamespacedName) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	delete(t.states, key)
+}
+
+// SyncStates returns the sync state of every policy reconciled since the controller started, sorted by namespace and
+// name.
+func (r *PolicyReconciler) SyncStates() []PolicySyncState {
+	r.syncStates.lock.Lock()
+
+	states := make([]PolicySyncState, 0, len(r.syncStates.states))
+	for _, state := range r.syncStates.states {
+		copied := *state
+		copied.Templates = append([]TemplateSyncState{}, state.Templates...)
+		copied.Conditions = append([]metav1.Condition{}, state.Conditions...)
+		states = append(states, copied)
+	}
+
+	r.syncStates.lock.Unlock()
+
+	for i := range states {
+		lastWrite := r.hubWrites.lastWrite(types.NamespacedName{Namespace: states[i].Namespace, Name: states[i].Name})
+		if !lastWrite.IsZero() {
+			lastWrite = lastWrite.UTC()
+			states[i].LastHubWriteTime = &lastWrite
+		}
+	}
+
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].Namespace != states[j].Namespace {
+			return states[i].Namespace < states[j].Namespace
+		}
+
+		return states[i].Name < states[j].Name
+	})
+
+	return states
+}
+
+// DebugHandler returns an HTTP handler serving the sync state of every policy as a JSON list at DebugPoliciesPath,
+// and the sync state of a single policy as JSON at DebugPoliciesPath/<namespace>/<name>.
+func (r *PolicyReconciler) DebugHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc(DebugPoliciesPath, func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, http.StatusOK, r.SyncStates())
+	})
+
+	mux.HandleFunc(DebugPoliciesPath+"/", func(w http.ResponseWriter, req *http.Request) {
+		namespace, name, found := strings.Cut(strings.TrimPrefix(req.URL.Path, DebugPoliciesPath+"/"), "/")
+		if !found || namespace == "" || name == "" || strings.Contains(name, "/") {
+			http.Error(w, "expected "+DebugPoliciesPath+"/<namespace>/<name>", http.StatusBadRequest)
+
+			return
+		}
+
+		for _, state := range r.SyncStates() {
+			if state.Namespace == namespace && state.Name == name {
+				writeJSON(w, http.StatusOK, state)
+
+				return
+			}
+		}
+
+		http.Error(w, "the policy wasn't reconciled since the controller started", http.StatusNotFound)
+	})
+
+	return mux
+}
+
+// writeJSON writes the input value as an indented JSON response.
+func writeJSON(w http.ResponseWriter, statusCode int, value interface{}) {
+	body, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if _, err := w.Write(append(body, '\n')); err != nil {
+		log.V(1).Info("Failed to write the debug response", "error", err.Error())
+	}
+}
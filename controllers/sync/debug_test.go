@@ -0,0 +1,86 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestDebugHandler(t *testing.T) {
+	t.Parallel()
+
+	r := &PolicyReconciler{}
+	key := types.NamespacedName{Namespace: "managed", Name: "policy"}
+	deleted := types.NamespacedName{Namespace: "managed", Name: "deleted"}
+
+	r.syncStates.start(key)
+	r.syncStates.observeStatus(key, 3, policiesv1.PolicyStatus{
+		Details: []*policiesv1.DetailsPerTemplate{{
+			TemplateMeta:    metav1.ObjectMeta{Name: "template"},
+			ComplianceState: policiesv1.NonCompliant,
+			History:         []policiesv1.ComplianceHistory{{EventName: "policy.1"}, {EventName: "policy.2"}},
+		}},
+	})
+	r.hubWrites.record(key)
+	r.syncStates.finish(key, reconcile.Result{RequeueAfter: time.Minute}, errors.New("conflict"))
+
+	r.syncStates.start(deleted)
+	r.syncStates.forget(deleted)
+	r.syncStates.finish(deleted, reconcile.Result{}, nil)
+
+	server := httptest.NewServer(r.DebugHandler())
+	defer server.Close()
+
+	states := []PolicySyncState{}
+	getJSON(t, server.URL+DebugPoliciesPath, http.StatusOK, &states)
+
+	if len(states) != 1 {
+		t.Fatalf("expected only the policy that wasn't deleted, got %+v", states)
+	}
+
+	state := PolicySyncState{}
+	getJSON(t, server.URL+DebugPoliciesPath+"/managed/policy", http.StatusOK, &state)
+
+	if state.LastReconcileOutcome != ReconcileFailed || state.LastError != "conflict" || state.EventCount != 3 ||
+		state.RequeueAfter != "1m0s" || state.LastHubWriteTime == nil || len(state.Templates) != 1 ||
+		state.Templates[0].ComplianceState != policiesv1.NonCompliant || state.Templates[0].HistoryLength != 2 {
+		t.Fatalf("unexpected sync state: %+v", state)
+	}
+
+	getJSON(t, server.URL+DebugPoliciesPath+"/managed/deleted", http.StatusNotFound, nil)
+	getJSON(t, server.URL+DebugPoliciesPath+"/managed", http.StatusBadRequest, nil)
+}
+
+// getJSON gets the input URL, verifies the status code of the response and decodes its JSON body into the input
+// value, if any.
+func getJSON(t *testing.T, url string, statusCode int, value interface{}) {
+	t.Helper()
+
+	resp, err := http.Get(url) //nolint:gosec,noctx
+	if err != nil {
+		t.Fatalf("failed to get %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != statusCode {
+		t.Fatalf("expected the status code %d from %s, got %d", statusCode, url, resp.StatusCode)
+	}
+
+	if value == nil {
+		return
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(value); err != nil {
+		t.Fatalf("failed to decode the response of %s: %v", url, err)
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"context"
+	"fmt"
+	gosync "sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// massDeletionRecheckInterval is how often a policy whose deletion is held by the mass deletion circuit breaker is
+// checked again
+const massDeletionRecheckInterval = time.Minute
+
+// missingOnHubTracker records when policies were first found to be missing on the hub, and which of their deletions
+// are held by the mass deletion circuit breaker.
+type missingOnHubTracker struct {
+	lock  gosync.Mutex
+	since map[types.NamespacedName]time.Time
+	held  map[types.NamespacedName]bool
+}
+
+// observe returns when the input policy was first found to be missing on the hub, recording the current time if it
+// was not missing before, and whether it was not missing before.
+func (t *missingOnHubTracker) observe(key types.NamespacedName) (time.Time, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.since == nil {
+		t.since = map[types.NamespacedName]time.Time{}
+	}
+
+	since, found := t.since[key]
+	if !found {
+		since = time.Now()
+		t.since[key] = since
+	}
+
+	return since, !found
+}
+
+// hold records that the deletion of the input policy is held and returns whether it wasn't held before.
+func (t *missingOnHubTracker) hold(key types.NamespacedName) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.held == nil {
+		t.held = map[types.NamespacedName]bool{}
+	}
+
+	if t.held[key] {
+		return false
+	}
+
+	t.held[key] = true
+
+	return true
+}
+
+// release records that the deletion of the input policy is no longer held.
+func (t *missingOnHubTracker) release(key types.NamespacedName) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	delete(t.held, key)
+}
+
+// forget stops tracking the input policy, because it was found on the hub or it was deleted.
+func (t *missingOnHubTracker) forget(key types.NamespacedName) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	delete(t.since, key)
+	delete(t.held, key)
+}
+
+// holdDeletion determines if the deletion of the input managed policy, which is missing on the hub, must be held. It
+// returns a positive duration after which the policy must be reconciled again if the deletion is held, either
+// because the deletion grace period has not elapsed or because too many policies are missing on the hub at the same
+// time.
+func (r *PolicyReconciler) holdDeletion(ctx context.Context, instance *policiesv1.Policy) (time.Duration, error) {
+	reqLogger := log.WithValues(
+		"Request.Namespace", instance.GetNamespace(), "Request.Name", instance.GetName(),
+		"HubNamespace", r.ClusterNamespaceOnHub,
+	)
+	key := client.ObjectKeyFromObject(instance)
+
+	if r.DeletionGracePeriod > 0 {
+		since, started := r.missingOnHub.observe(key)
+
+		missingFor := time.Since(since)
+		if missingFor < r.DeletionGracePeriod {
+			remaining := r.DeletionGracePeriod - missingFor
+
+			reqLogger.Info("Waiting for the deletion grace period before deleting the managed policy",
+				"remaining", remaining.String())
+
+			// an event on the policy triggers a reconcile, so it is only recorded when the grace period starts
+			if started {
+				r.ManagedRecorder.Event(instance, "Normal", "PolicyDeletionPending", fmt.Sprintf(
+					"The policy is missing in the hub namespace %s, so it will be deleted after the deletion grace "+
+						"period of %s unless it is restored on the hub.",
+					r.ClusterNamespaceOnHub, r.DeletionGracePeriod,
+				))
+			}
+
+			return remaining, nil
+		}
+	}
+
+	if r.MassDeletionThreshold <= 0 {
+		return 0, nil
+	}
+
+	missing, total, err := r.countMissingOnHub(ctx, instance.GetNamespace())
+	if err != nil {
+		reqLogger.Error(err, "Failed to count the policies missing on the hub, will requeue the request")
+
+		return 0, err
+	}
+
+	// A single policy is allowed to be deleted, otherwise the last policy on the cluster could never be deleted
+	if missing <= 1 || float64(missing)/float64(total) <= r.MassDeletionThreshold {
+		r.missingOnHub.release(key)
+
+		return 0, nil
+	}
+
+	msg := fmt.Sprintf(
+		"Deletion of the policy is held since %d of the %d policies in namespace %s are missing in the hub "+
+			"namespace %s, which exceeds the mass deletion threshold of %.0f%%. Verify the cluster namespace on the "+
+			"hub, or delete the policies manually if the deletion is intended.",
+		missing, total, instance.GetNamespace(), r.ClusterNamespaceOnHub, r.MassDeletionThreshold*100,
+	)
+
+	reqLogger.Info("Holding the deletion of the managed policy", "missing", missing, "total", total)
+
+	// an event on the policy triggers a reconcile, so it is only recorded when the hold starts
+	if r.missingOnHub.hold(key) {
+		r.ManagedRecorder.Event(instance, "Warning", "PolicyDeletionHeld", msg)
+	}
+
+	return massDeletionRecheckInterval, nil
+}
+
+// countMissingOnHub returns the number of policies in the input namespace on the managed cluster which are missing in
+// the cluster namespace on the hub, and the total number of policies in the input namespace.
+func (r *PolicyReconciler) countMissingOnHub(ctx context.Context, namespace string) (int, int, error) {
+	managedPolicies := &policiesv1.PolicyList{}
+
+	err := r.ManagedClient.List(ctx, managedPolicies, client.InNamespace(namespace))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	hubPolicies := &metav1.PartialObjectMetadataList{}
+	hubPolicies.SetGroupVersionKind(policiesv1.SchemeGroupVersion.WithKind("PolicyList"))
+
+	err = r.HubClient.List(ctx, hubPolicies, client.InNamespace(r.ClusterNamespaceOnHub))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	onHub := make(map[string]bool, len(hubPolicies.Items))
+	for _, hubPolicy := range hubPolicies.Items {
+		onHub[hubPolicy.GetName()] = true
+	}
+
+	missing := 0
+
+	for _, managedPolicy := range managedPolicies.Items {
+		if !onHub[managedPolicy.GetName()] {
+			missing++
+		}
+	}
+
+	return missing, len(managedPolicies.Items), nil
+}
@@ -0,0 +1,202 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// deletionTestReconciler returns a reconciler with the input number of policies on the managed cluster, of which the
+// input number are missing on the hub.
+func deletionTestReconciler(t *testing.T, total int, missing int) *PolicyReconciler {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	if err := policiesv1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build the scheme: %v", err)
+	}
+
+	managedPolicies := []client.Object{}
+	hubPolicies := []client.Object{}
+
+	for i := 0; i < total; i++ {
+		name := fmt.Sprintf("policy-%d", i)
+		managedPolicies = append(managedPolicies, &policiesv1.Policy{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "managed"},
+		})
+
+		if i >= missing {
+			hubPolicies = append(hubPolicies, &policiesv1.Policy{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "cluster"},
+			})
+		}
+	}
+
+	return &PolicyReconciler{
+		HubClient:             fake.NewClientBuilder().WithScheme(scheme).WithObjects(hubPolicies...).Build(),
+		ManagedClient:         fake.NewClientBuilder().WithScheme(scheme).WithObjects(managedPolicies...).Build(),
+		ManagedRecorder:       record.NewFakeRecorder(10),
+		ClusterNamespaceOnHub: "cluster",
+	}
+}
+
+func TestHoldDeletion(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		gracePeriod  time.Duration
+		missingSince time.Duration
+		threshold    float64
+		total        int
+		missing      int
+		held         bool
+	}{
+		"no safeguard": {total: 4, missing: 4},
+		"within the grace period": {
+			gracePeriod: time.Hour, missingSince: time.Minute, total: 4, missing: 1, held: true,
+		},
+		"after the grace period": {gracePeriod: time.Hour, missingSince: 2 * time.Hour, total: 4, missing: 1},
+		"below the threshold":    {threshold: 0.5, total: 4, missing: 2},
+		"above the threshold":    {threshold: 0.5, total: 4, missing: 3, held: true},
+		"single missing policy":  {threshold: 0.5, total: 1, missing: 1},
+		"above the threshold after the grace period": {
+			gracePeriod: time.Hour, missingSince: 2 * time.Hour, threshold: 0.5, total: 4, missing: 4, held: true,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r := deletionTestReconciler(t, test.total, test.missing)
+			r.DeletionGracePeriod = test.gracePeriod
+			r.MassDeletionThreshold = test.threshold
+
+			instance := &policiesv1.Policy{ObjectMeta: metav1.ObjectMeta{Name: "policy-0", Namespace: "managed"}}
+			if test.missingSince > 0 {
+				r.missingOnHub.since = map[client.ObjectKey]time.Time{
+					client.ObjectKeyFromObject(instance): time.Now().Add(-test.missingSince),
+				}
+			}
+
+			requeueAfter, err := r.holdDeletion(context.TODO(), instance)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if test.held != (requeueAfter > 0) {
+				t.Fatalf("expected the deletion to be held: %v, got a requeue after %s", test.held, requeueAfter)
+			}
+
+			if test.gracePeriod > 0 && test.missingSince < test.gracePeriod &&
+				(requeueAfter > test.gracePeriod-test.missingSince || requeueAfter < test.gracePeriod/2) {
+				t.Fatalf("expected a requeue at the end of the grace period, got %s", requeueAfter)
+			}
+		})
+	}
+}
+
+func TestHoldDeletionEvents(t *testing.T) {
+	t.Parallel()
+
+	t.Run("mass deletion", func(t *testing.T) {
+		t.Parallel()
+
+		r := deletionTestReconciler(t, 4, 3)
+		r.MassDeletionThreshold = 0.5
+		recorder := r.ManagedRecorder.(*record.FakeRecorder)
+		instance := &policiesv1.Policy{ObjectMeta: metav1.ObjectMeta{Name: "policy-0", Namespace: "managed"}}
+
+		for i := 0; i < 3; i++ {
+			if requeueAfter, err := r.holdDeletion(context.TODO(), instance); err != nil || requeueAfter == 0 {
+				t.Fatalf("expected the deletion to be held, got %s and %v", requeueAfter, err)
+			}
+		}
+
+		if len(recorder.Events) != 1 {
+			t.Fatalf("expected a single event while the deletion is held, got %d", len(recorder.Events))
+		}
+
+		<-recorder.Events
+
+		// the policies are restored on the hub, so the hold is released
+		for i := 1; i < 3; i++ {
+			hubPlc := &policiesv1.Policy{
+				ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("policy-%d", i), Namespace: "cluster"},
+			}
+			if err := r.HubClient.Create(context.TODO(), hubPlc); err != nil {
+				t.Fatalf("failed to restore the hub policy: %v", err)
+			}
+		}
+
+		if requeueAfter, err := r.holdDeletion(context.TODO(), instance); err != nil || requeueAfter != 0 {
+			t.Fatalf("expected the deletion to be released, got %s and %v", requeueAfter, err)
+		}
+
+		// the policies are missing on the hub again, so a new hold is reported
+		for i := 1; i < 3; i++ {
+			hubPlc := &policiesv1.Policy{
+				ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("policy-%d", i), Namespace: "cluster"},
+			}
+			if err := r.HubClient.Delete(context.TODO(), hubPlc); err != nil {
+				t.Fatalf("failed to delete the hub policy: %v", err)
+			}
+		}
+
+		if requeueAfter, err := r.holdDeletion(context.TODO(), instance); err != nil || requeueAfter == 0 {
+			t.Fatalf("expected the deletion to be held again, got %s and %v", requeueAfter, err)
+		}
+
+		if len(recorder.Events) != 1 {
+			t.Fatalf("expected an event for the new hold, got %d", len(recorder.Events))
+		}
+	})
+
+	t.Run("grace period", func(t *testing.T) {
+		t.Parallel()
+
+		r := deletionTestReconciler(t, 4, 1)
+		r.DeletionGracePeriod = time.Hour
+		recorder := r.ManagedRecorder.(*record.FakeRecorder)
+		instance := &policiesv1.Policy{ObjectMeta: metav1.ObjectMeta{Name: "policy-0", Namespace: "managed"}}
+
+		for i := 0; i < 3; i++ {
+			if requeueAfter, err := r.holdDeletion(context.TODO(), instance); err != nil || requeueAfter == 0 {
+				t.Fatalf("expected the deletion to be held, got %s and %v", requeueAfter, err)
+			}
+		}
+
+		if len(recorder.Events) != 1 {
+			t.Fatalf("expected a single event while the deletion is held, got %d", len(recorder.Events))
+		}
+
+		if event := <-recorder.Events; !strings.Contains(event, "PolicyDeletionPending") ||
+			!strings.Contains(event, "grace period of 1h0m0s") {
+			t.Fatalf("expected an event explaining the grace period, got %q", event)
+		}
+
+		// the policy is found on the hub again, so a new grace period is reported
+		r.missingOnHub.forget(client.ObjectKeyFromObject(instance))
+
+		if requeueAfter, err := r.holdDeletion(context.TODO(), instance); err != nil || requeueAfter == 0 {
+			t.Fatalf("expected the deletion to be held, got %s and %v", requeueAfter, err)
+		}
+
+		if len(recorder.Events) != 1 {
+			t.Fatalf("expected an event for the new grace period, got %d", len(recorder.Events))
+		}
+	})
+}
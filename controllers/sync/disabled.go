@@ -0,0 +1,151 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// ConditionDisabled is true while the hub policy is disabled. Once the policy is enabled again, it becomes false and
+// its last transition time is when the policy was enabled; the events emitted before then are ignored. It is only
+// set on policies that were disabled at some point. Like the other conditions, it is kept in the conditions ConfigMap
+// of the policy, also in the status-only mode, so the time the policy was enabled survives a restart.
+const ConditionDisabled = "Disabled"
+
+// syncDisabled handles a policy that is disabled on the hub. Its status is cleared on the managed and hub policies,
+// once, and is then neither computed from the events nor written to the hub until the policy is enabled again.
+func (r *PolicyReconciler) syncDisabled(
+	ctx context.Context, key types.NamespacedName, instance *policiesv1.Policy, hubPlc *policiesv1.Policy,
+	conditions []metav1.Condition,
+) (reconcile.Result, error) {
+	reqLogger := log.WithValues("Request.Namespace", instance.GetNamespace(), "Request.Name", instance.GetName())
+
+	if !equality.Semantic.DeepEqual(instance.Status, policiesv1.PolicyStatus{}) {
+		reqLogger.Info("The policy is disabled, clearing its status on managed")
+
+		oldStatus := instance.Status
+		instance.Status = policiesv1.PolicyStatus{}
+
+		err := r.ManagedClient.Status().Update(ctx, instance)
+		if err != nil {
+			reqLogger.Error(err, "Failed to clear the policy status on managed")
+			r.reportFailure(ctx, instance, append(conditions,
+				syncedCondition("ManagedStatusUpdateFailed", "", err))...)
+
+			return reconcile.Result{}, err
+		}
+
+		r.notifyComplianceChanges(instance, oldStatus, instance.Status)
+		r.audit(instance, AuditRecord{
+			Action: AuditManagedStatusUpdated,
+			Reason: "The policy is disabled on the hub",
+			Before: oldStatus,
+			After:  instance.Status,
+		})
+	}
+
+	hubStatus := policiesv1.PolicyStatus{}
+
+	if os.Getenv("ON_MULTICLUSTERHUB") != "true" {
+		var err error
+
+		hubStatus, err = r.readHubStatus(ctx, instance, hubPlc, conditions)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	if !equality.Semantic.DeepEqual(hubStatus, policiesv1.PolicyStatus{}) {
+		reqLogger.Info("The policy is disabled, clearing its status on the hub")
+
+		hubPlc.Status = policiesv1.PolicyStatus{}
+
+		err := r.statusSink().WriteStatus(ctx, hubPlc)
+		if err != nil {
+			reqLogger.Error(err, "Failed to clear the policy status on the hub")
+			r.reportFailure(ctx, instance, append(conditions,
+				syncedCondition("HubStatusUpdateFailed", "", err))...)
+
+			return reconcile.Result{}, err
+		}
+
+		r.audit(instance, AuditRecord{
+			Action: AuditHubStatusUpdated,
+			Reason: "The policy is disabled on the hub",
+			Before: hubStatus,
+			After:  hubPlc.Status,
+		})
+	}
+
+	r.transitions.forget(key)
+	r.syncStates.observeStatus(key, 0, instance.Status)
+
+	conditions = append(conditions,
+		metav1.Condition{
+			Type:    ConditionDisabled,
+			Status:  metav1.ConditionTrue,
+			Reason:  "PolicyDisabled",
+			Message: "The policy is disabled on the hub",
+		},
+		syncedCondition(
+			"PolicyDisabled", "The policy is disabled, so its status is cleared and not synced to the hub", nil,
+		),
+		metav1.Condition{
+			Type:    ConditionCompliant,
+			Status:  metav1.ConditionUnknown,
+			Reason:  "PolicyDisabled",
+			Message: "The policy is disabled, so it has no compliance state",
+		},
+	)
+
+	err := r.patchConditions(ctx, instance, conditions...)
+	if err != nil {
+		reqLogger.Error(err, "Failed to set the conditions on the managed policy, will requeue the request")
+
+		return reconcile.Result{}, err
+	}
+
+	if r.ExportPolicyReports {
+		// the report of a disabled policy has no results
+		if err := r.exportPolicyReport(ctx, instance); err != nil {
+			reqLogger.Error(err, "Failed to export the policy report, will requeue the request")
+
+			return reconcile.Result{}, err
+		}
+	}
+
+	return r.resyncResult(), nil
+}
+
+// enabledCondition returns the Disabled condition of an enabled policy and when the policy was enabled again, or nil
+// and the zero time if the policy was never disabled.
+func enabledCondition(conditions []metav1.Condition) (*metav1.Condition, time.Time) {
+	existing := meta.FindStatusCondition(conditions, ConditionDisabled)
+	if existing == nil {
+		return nil, time.Time{}
+	}
+
+	if existing.Status == metav1.ConditionFalse {
+		return existing, existing.LastTransitionTime.Time
+	}
+
+	// the policy is enabled again in this reconcile
+	now := metav1.Now()
+
+	return &metav1.Condition{
+		Type:               ConditionDisabled,
+		Status:             metav1.ConditionFalse,
+		LastTransitionTime: now,
+		Reason:             "PolicyEnabled",
+		Message:            "The policy was enabled again, so the events emitted before then are ignored",
+	}, now.Time
+}
@@ -0,0 +1,68 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+)
+
+func TestEnabledConditionAfterRestart(t *testing.T) {
+	t.Parallel()
+
+	for _, statusOnly := range []bool{false, true} {
+		instance := &policiesv1.Policy{ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "managed"}}
+		r := conditionsTestReconciler(t, instance)
+		r.StatusOnly = statusOnly
+
+		err := r.patchConditions(context.TODO(), instance, metav1.Condition{
+			Type:    ConditionDisabled,
+			Status:  metav1.ConditionTrue,
+			Reason:  "PolicyDisabled",
+			Message: "The policy is disabled on the hub",
+		})
+		if err != nil {
+			t.Fatalf("failed to set the conditions: %v", err)
+		}
+
+		conditions, err := r.conditions(context.TODO(), instance)
+		if err != nil {
+			t.Fatalf("failed to get the conditions: %v", err)
+		}
+
+		enabled, _ := enabledCondition(conditions)
+		if enabled == nil {
+			t.Fatal("expected the Disabled condition of the enabled policy")
+		}
+
+		if err := r.patchConditions(context.TODO(), instance, *enabled); err != nil {
+			t.Fatalf("failed to set the conditions: %v", err)
+		}
+
+		conditions, err = r.conditions(context.TODO(), instance)
+		if err != nil {
+			t.Fatalf("failed to get the conditions: %v", err)
+		}
+
+		_, enabledSince := enabledCondition(conditions)
+
+		// a reconciler starting with the same cluster, such as after a restart, must still ignore the events
+		// emitted before the policy was enabled
+		restarted := &PolicyReconciler{ManagedClient: r.ManagedClient, StatusOnly: statusOnly}
+
+		conditions, err = restarted.conditions(context.TODO(), instance)
+		if err != nil {
+			t.Fatalf("failed to get the conditions: %v", err)
+		}
+
+		_, restartedSince := enabledCondition(conditions)
+		if restartedSince.IsZero() || !restartedSince.Equal(enabledSince.Truncate(time.Second)) {
+			t.Fatalf("status-only %v: expected the policy to be enabled since %s after a restart, got %s",
+				statusOnly, enabledSince, restartedSince)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	gosync "sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+)
+
+// specDriftTracker records which managed policies drifted from their hub policy in the status-only mode.
+type specDriftTracker struct {
+	lock    gosync.Mutex
+	drifted map[types.NamespacedName]bool
+}
+
+// observe records whether the input policy drifted and returns whether the drift started with this observation.
+func (t *specDriftTracker) observe(key types.NamespacedName, drifted bool) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if !drifted {
+		delete(t.drifted, key)
+
+		return false
+	}
+
+	if t.drifted == nil {
+		t.drifted = map[types.NamespacedName]bool{}
+	}
+
+	started := !t.drifted[key]
+	t.drifted[key] = true
+
+	return started
+}
+
+// forget stops tracking the input policy, because it was deleted.
+func (t *specDriftTracker) forget(key types.NamespacedName) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	delete(t.drifted, key)
+	specDriftGauge.DeleteLabelValues(key.Namespace, key.Name)
+}
+
+// reportSpecDrift reports whether the spec, labels or annotations of the managed policy differ from the hub policy
+// through the spec drift metric and a warning event, without reverting the managed policy. It returns the SpecInSync
+// condition of the policy.
+func (r *PolicyReconciler) reportSpecDrift(instance *policiesv1.Policy, hubPlc *policiesv1.Policy) metav1.Condition {
+	key := types.NamespacedName{Namespace: instance.GetNamespace(), Name: instance.GetName()}
+	condition := metav1.Condition{
+		Type:    ConditionSpecInSync,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Matched",
+		Message: "The spec, labels and annotations of the managed policy match the hub policy",
+	}
+
+	if r.MetadataRules.policyMatchesHub(instance, hubPlc) {
+		r.specDrift.observe(key, false)
+		specDriftGauge.WithLabelValues(instance.GetNamespace(), instance.GetName()).Set(0)
+
+		return condition
+	}
+
+	log.Info("Found mismatch with hub and managed policies, not reverting it in the status-only mode",
+		"Request.Namespace", instance.GetNamespace(), "Request.Name", instance.GetName())
+
+	specDriftGauge.WithLabelValues(instance.GetNamespace(), instance.GetName()).Set(1)
+
+	condition.Status = metav1.ConditionFalse
+	condition.Reason = "Drifted"
+	condition.Message = "The spec, labels or annotations of the managed policy differ from the hub policy " +
+		"and are not reverted since the controller runs in the status-only mode"
+
+	// an event on the policy triggers a reconcile, so it is only recorded when the drift starts
+	if r.specDrift.observe(key, true) {
+		r.ManagedRecorder.Event(instance, "Warning", "PolicySpecDrift", condition.Message)
+	}
+
+	return condition
+}
@@ -0,0 +1,125 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReportSpecDrift(t *testing.T) {
+	t.Parallel()
+
+	recorder := record.NewFakeRecorder(10)
+	r := &PolicyReconciler{ManagedRecorder: recorder, StatusOnly: true}
+	hubPlc := &policiesv1.Policy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "cluster"},
+		Spec:       policiesv1.PolicySpec{RemediationAction: policiesv1.Enforce},
+	}
+	instance := &policiesv1.Policy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "managed"},
+		Spec:       policiesv1.PolicySpec{RemediationAction: policiesv1.Enforce},
+	}
+
+	steps := []struct {
+		remediationAction policiesv1.RemediationAction
+		status            metav1.ConditionStatus
+		events            int
+	}{
+		{policiesv1.Enforce, metav1.ConditionTrue, 0},
+		// the drift starts
+		{policiesv1.Inform, metav1.ConditionFalse, 1},
+		// the drift continues, which must not record more events since they trigger reconciles
+		{policiesv1.Inform, metav1.ConditionFalse, 0},
+		{policiesv1.Inform, metav1.ConditionFalse, 0},
+		{policiesv1.Enforce, metav1.ConditionTrue, 0},
+		// a new drift
+		{policiesv1.Inform, metav1.ConditionFalse, 1},
+	}
+
+	for i, step := range steps {
+		instance.Spec.RemediationAction = step.remediationAction
+
+		condition := r.reportSpecDrift(instance, hubPlc)
+		if condition.Type != ConditionSpecInSync || condition.Status != step.status {
+			t.Fatalf("step %d: expected the SpecInSync condition to be %s, got %+v", i, step.status, condition)
+		}
+
+		if len(recorder.Events) != step.events {
+			t.Fatalf("step %d: expected %d events, got %d", i, step.events, len(recorder.Events))
+		}
+
+		for len(recorder.Events) > 0 {
+			<-recorder.Events
+		}
+	}
+}
+
+func TestPatchConditionsStatusOnly(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	if err := policiesv1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build the scheme: %v", err)
+	}
+
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build the scheme: %v", err)
+	}
+
+	for _, statusOnly := range []bool{false, true} {
+		instance := &policiesv1.Policy{ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "managed"}}
+		key := client.ObjectKeyFromObject(instance)
+		r := &PolicyReconciler{
+			ManagedClient: fake.NewClientBuilder().WithScheme(scheme).WithObjects(instance.DeepCopy()).Build(),
+			StatusOnly:    statusOnly,
+		}
+
+		if err := r.ManagedClient.Get(context.TODO(), key, instance); err != nil {
+			t.Fatalf("failed to get the policy: %v", err)
+		}
+
+		r.syncStates.start(key)
+
+		err := r.patchConditions(context.TODO(), instance, hubReachableCondition(nil))
+		if err != nil {
+			t.Fatalf("failed to set the conditions: %v", err)
+		}
+
+		managedPlc := &policiesv1.Policy{}
+		if err := r.ManagedClient.Get(context.TODO(), key, managedPlc); err != nil {
+			t.Fatalf("failed to get the policy: %v", err)
+		}
+
+		if managedPlc.GetResourceVersion() != instance.GetResourceVersion() || len(managedPlc.GetAnnotations()) != 0 {
+			t.Fatalf("expected the managed policy to be unchanged, got %+v", managedPlc.ObjectMeta)
+		}
+
+		// the conditions are kept in the ConfigMap in both modes, so they survive a restart
+		restarted := &PolicyReconciler{ManagedClient: r.ManagedClient, StatusOnly: statusOnly}
+
+		conditions, err := restarted.conditions(context.TODO(), instance)
+		if err != nil {
+			t.Fatalf("failed to get the conditions: %v", err)
+		}
+
+		if condition := meta.FindStatusCondition(conditions, ConditionHubReachable); condition == nil {
+			t.Fatalf("expected the condition to be returned, got %+v", conditions)
+		}
+
+		states := r.SyncStates()
+		if len(states) != 1 || len(states[0].Conditions) != 1 ||
+			states[0].Conditions[0].Type != ConditionHubReachable {
+			t.Fatalf("expected the condition in the sync state, got %+v", states)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+)
+
+// ConditionFlapping is true when the compliance state of a policy template changed at least FlapThreshold times
+// within FlapWindow. Its message lists the flapping policy templates.
+const ConditionFlapping = "Flapping"
+
+// hubWriteTracker records when the status of each policy was last written to the hub, to damp the hub writes caused
+// by flapping policy templates.
+type hubWriteTracker struct {
+	lock gosync.Mutex
+	last map[types.NamespacedName]time.Time
+}
+
+// lastWrite returns when the status of the input policy was last written to the hub, or the zero time if it was not
+// written since the controller started.
+func (t *hubWriteTracker) lastWrite(key types.NamespacedName) time.Time {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.last[key]
+}
+
+// record records that the status of the input policy was just written to the hub.
+func (t *hubWriteTracker) record(key types.NamespacedName) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.last == nil {
+		t.last = map[types.NamespacedName]time.Time{}
+	}
+
+	t.last[key] = time.Now()
+}
+
+// forget stops tracking the input policy, because it was deleted.
+func (t *hubWriteTracker) forget(key types.NamespacedName) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	delete(t.last, key)
+	dampedHubWritesCounter.DeleteLabelValues(key.Namespace, key.Name)
+}
+
+// historyCompliance returns the compliance state reported by the message of a history entry.
+func historyCompliance(message string) policiesv1.ComplianceState {
+	if strings.HasPrefix(strings.ToLower(strings.TrimSpace(
+		strings.TrimPrefix(message, "(combined from similar events):"))), "compliant") {
+		return policiesv1.Compliant
+	}
+
+	return policiesv1.NonCompliant
+}
+
+// complianceChanges returns how many times the compliance state changed in the input history, newest entry first,
+// counting only the entries within the input window. A zero window counts the whole history.
+func complianceChanges(history []policiesv1.ComplianceHistory, window time.Duration) int {
+	changes := 0
+
+	for i := 1; i < len(history); i++ {
+		if window > 0 && time.Since(history[i].LastTimestamp.Time) > window {
+			break
+		}
+
+		if historyCompliance(history[i].Message) != historyCompliance(history[i-1].Message) {
+			changes++
+		}
+	}
+
+	return changes
+}
+
+// flappingTemplates returns the sorted names of the policy templates in the input status whose compliance state
+// changed at least FlapThreshold times within FlapWindow. It returns nil when the detection is disabled.
+func (r *PolicyReconciler) flappingTemplates(status policiesv1.PolicyStatus) []string {
+	if r.FlapThreshold <= 0 {
+		return nil
+	}
+
+	flapping := []string{}
+
+	for _, dpt := range status.Details {
+		if complianceChanges(dpt.History, r.FlapWindow) >= r.FlapThreshold {
+			flapping = append(flapping, dpt.TemplateMeta.Name)
+		}
+	}
+
+	sort.Strings(flapping)
+
+	return flapping
+}
+
+// flappingCondition returns the Flapping condition of the input flapping policy templates.
+func (r *PolicyReconciler) flappingCondition(flapping []string) metav1.Condition {
+	if len(flapping) == 0 {
+		return metav1.Condition{
+			Type:    ConditionFlapping,
+			Status:  metav1.ConditionFalse,
+			Reason:  "Stable",
+			Message: "No policy template is flapping between compliance states",
+		}
+	}
+
+	return metav1.Condition{
+		Type:   ConditionFlapping,
+		Status: metav1.ConditionTrue,
+		Reason: "TemplatesFlapping",
+		Message: fmt.Sprintf("The compliance state of the policy templates %s changed at least %d times within %s",
+			strings.Join(flapping, ", "), r.FlapThreshold, r.FlapWindow),
+	}
+}
+
+// onlyFlappingChanged returns whether the input statuses only differ in the details of the input flapping policy
+// templates. The overall compliance state is ignored since it is derived from the details.
+func onlyFlappingChanged(hubStatus, newStatus policiesv1.PolicyStatus, flapping []string) bool {
+	if len(flapping) == 0 || len(hubStatus.Details) != len(newStatus.Details) {
+		return false
+	}
+
+	isFlapping := make(map[string]bool, len(flapping))
+	for _, name := range flapping {
+		isFlapping[name] = true
+	}
+
+	for i, dpt := range newStatus.Details {
+		hubDpt := hubStatus.Details[i]
+		if hubDpt == nil || dpt == nil || hubDpt.TemplateMeta.Name != dpt.TemplateMeta.Name {
+			return false
+		}
+
+		if !isFlapping[dpt.TemplateMeta.Name] && !equality.Semantic.DeepEqual(hubDpt, dpt) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// dampHubWrite returns how long the hub status write of the input policy must still be delayed because it is only
+// caused by flapping policy templates, or zero if the status must be written now. The newest state is written once
+// the damping period since the last hub write elapses, or as soon as a template that isn't flapping changes.
+func (r *PolicyReconciler) dampHubWrite(
+	key types.NamespacedName, hubStatus, newStatus policiesv1.PolicyStatus, flapping []string,
+) time.Duration {
+	if r.FlapDampingPeriod <= 0 || !onlyFlappingChanged(hubStatus, newStatus, flapping) {
+		return 0
+	}
+
+	lastWrite := r.hubWrites.lastWrite(key)
+	if lastWrite.IsZero() {
+		return 0
+	}
+
+	remaining := r.FlapDampingPeriod - time.Since(lastWrite)
+	if remaining < 0 {
+		return 0
+	}
+
+	return remaining
+}
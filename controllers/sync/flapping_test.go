@@ -0,0 +1,137 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+)
+
+func historyOf(ages map[time.Duration]string) []policiesv1.ComplianceHistory {
+	history := []policiesv1.ComplianceHistory{}
+
+	for age := time.Duration(0); age <= 10*time.Minute; age += time.Minute {
+		if message, found := ages[age]; found {
+			history = append(history, policiesv1.ComplianceHistory{
+				LastTimestamp: metav1.NewTime(time.Now().Add(-age)),
+				Message:       message,
+			})
+		}
+	}
+
+	return history
+}
+
+func TestFlappingTemplates(t *testing.T) {
+	t.Parallel()
+
+	r := &PolicyReconciler{FlapThreshold: 3, FlapWindow: 5 * time.Minute}
+
+	status := policiesv1.PolicyStatus{
+		Details: []*policiesv1.DetailsPerTemplate{
+			{
+				TemplateMeta: metav1.ObjectMeta{Name: "flapping"},
+				History: historyOf(map[time.Duration]string{
+					0:               "Compliant; notification - ok",
+					time.Minute:     "NonCompliant; violation - missing",
+					2 * time.Minute: "Compliant; notification - ok",
+					3 * time.Minute: "NonCompliant; violation - missing",
+				}),
+			},
+			{
+				TemplateMeta: metav1.ObjectMeta{Name: "flapped-long-ago"},
+				History: historyOf(map[time.Duration]string{
+					0:                "Compliant; notification - ok",
+					6 * time.Minute:  "NonCompliant; violation - missing",
+					7 * time.Minute:  "Compliant; notification - ok",
+					8 * time.Minute:  "NonCompliant; violation - missing",
+					10 * time.Minute: "Compliant; notification - ok",
+				}),
+			},
+			{
+				TemplateMeta: metav1.ObjectMeta{Name: "stable"},
+				History: historyOf(map[time.Duration]string{
+					0:           "NonCompliant; violation - missing",
+					time.Minute: "NonCompliant; violation - still missing",
+				}),
+			},
+		},
+	}
+
+	if flapping := r.flappingTemplates(status); !reflect.DeepEqual(flapping, []string{"flapping"}) {
+		t.Fatalf("expected only the flapping template to be flapping, got %v", flapping)
+	}
+
+	if flapping := (&PolicyReconciler{}).flappingTemplates(status); flapping != nil {
+		t.Fatalf("expected no detection when it is disabled, got %v", flapping)
+	}
+}
+
+func TestDampHubWrite(t *testing.T) {
+	t.Parallel()
+
+	key := types.NamespacedName{Namespace: "managed", Name: "policy"}
+	detail := func(name string, state policiesv1.ComplianceState) *policiesv1.DetailsPerTemplate {
+		return &policiesv1.DetailsPerTemplate{TemplateMeta: metav1.ObjectMeta{Name: name}, ComplianceState: state}
+	}
+	hubStatus := policiesv1.PolicyStatus{
+		ComplianceState: policiesv1.Compliant,
+		Details: []*policiesv1.DetailsPerTemplate{
+			detail("a", policiesv1.Compliant), detail("b", policiesv1.Compliant),
+		},
+	}
+	flappingChanged := policiesv1.PolicyStatus{
+		ComplianceState: policiesv1.NonCompliant,
+		Details: []*policiesv1.DetailsPerTemplate{
+			detail("a", policiesv1.NonCompliant), detail("b", policiesv1.Compliant),
+		},
+	}
+	otherChanged := policiesv1.PolicyStatus{
+		ComplianceState: policiesv1.NonCompliant,
+		Details: []*policiesv1.DetailsPerTemplate{
+			detail("a", policiesv1.NonCompliant), detail("b", policiesv1.NonCompliant),
+		},
+	}
+
+	r := &PolicyReconciler{FlapDampingPeriod: time.Minute}
+
+	if damped := r.dampHubWrite(key, hubStatus, flappingChanged, []string{"a"}); damped != 0 {
+		t.Fatalf("expected the first hub write not to be damped, got %s", damped)
+	}
+
+	r.hubWrites.record(key)
+
+	if damped := r.dampHubWrite(key, hubStatus, flappingChanged, []string{"a"}); damped <= 0 {
+		t.Fatal("expected the hub write of a flapping template to be damped")
+	}
+
+	if damped := r.dampHubWrite(key, hubStatus, otherChanged, []string{"a"}); damped != 0 {
+		t.Fatalf("expected the hub write of a stable template not to be damped, got %s", damped)
+	}
+}
+
+func TestHubWriteTrackerForgetMetrics(t *testing.T) {
+	t.Parallel()
+
+	// a namespace of its own since the metrics are global
+	key := types.NamespacedName{Namespace: "damping-metrics", Name: "policy"}
+	tracker := &hubWriteTracker{}
+
+	tracker.record(key)
+	dampedHubWritesCounter.WithLabelValues(key.Namespace, key.Name).Inc()
+
+	if series := policySeries(t, dampedHubWritesCounter, key); series != 1 {
+		t.Fatalf("expected 1 damped hub writes series, got %d", series)
+	}
+
+	tracker.forget(key)
+
+	if series := policySeries(t, dampedHubWritesCounter, key); series != 0 || !tracker.lastWrite(key).IsZero() {
+		t.Fatalf("expected the damped hub writes series of the deleted policy to be removed, got %d", series)
+	}
+}
@@ -0,0 +1,285 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// Inspect fetches the managed policy, the hub policy and the events of the input managed policy, computes the status
+// as a reconcile would, without writing anything, and writes to the input writer the compliance state of each policy
+// template in the managed, hub and computed statuses, the diffs of the managed and hub statuses with the computed
+// status, and the spec mismatch between the managed and hub policies.
+func (r *PolicyReconciler) Inspect(ctx context.Context, key types.NamespacedName, out io.Writer) error {
+	instance := &policiesv1.Policy{}
+	if err := r.ManagedClient.Get(ctx, key, instance); err != nil {
+		return fmt.Errorf("failed to get the managed policy: %w", err)
+	}
+
+	hubPlc := &policiesv1.Policy{}
+
+	if r.sinkOnly() {
+		hubPlc = r.sinkPolicy(instance)
+	} else {
+		err := r.HubClient.Get(ctx, types.NamespacedName{Namespace: r.ClusterNamespaceOnHub, Name: key.Name}, hubPlc)
+		if err != nil {
+			return fmt.Errorf("failed to get the hub policy: %w", err)
+		}
+	}
+
+	hubStatus, err := r.statusSink().ReadStatus(ctx, hubPlc)
+	if err != nil {
+		return fmt.Errorf("failed to read the hub policy status: %w", err)
+	}
+
+	eventList := &corev1.EventList{}
+	if err := r.ManagedClient.List(ctx, eventList, client.InNamespace(key.Namespace)); err != nil {
+		return fmt.Errorf("failed to list the events: %w", err)
+	}
+
+	conditions, err := r.conditions(ctx, instance)
+	if err != nil {
+		return err
+	}
+
+	_, enabledSince := enabledCondition(conditions)
+	eventForPolicyMap := policyEvents(instance, eventList.Items, enabledSince)
+
+	// the status computation modifies the details of the input policy
+	computedPlc := instance.DeepCopy()
+	computedPlc.Status, _ = r.computeStatus(computedPlc, eventForPolicyMap, logr.Discard())
+	computedPlc.Status = r.MessageRedaction.managedStatus(computedPlc.Status, instance.Status)
+
+	if reduction, size := r.fitStatus(computedPlc, hubPlc); reduction != nil {
+		_, err := fmt.Fprintf(out, "The computed status is reduced to %d bytes to fit the status size budget: %s\n\n",
+			size, reduction)
+		if err != nil {
+			return err
+		}
+	}
+
+	computed := computedPlc.Status
+
+	if hubPlc.Spec.Disabled {
+		computed = policiesv1.PolicyStatus{}
+	}
+
+	sections := []func() error{
+		func() error {
+			_, err := fmt.Fprintf(out, "Policy %s/%s, hub policy %s/%s, %d matching events\n\n",
+				key.Namespace, key.Name, r.ClusterNamespaceOnHub, key.Name, eventCount(eventForPolicyMap))
+
+			return err
+		},
+		func() error { return writeComplianceTable(out, instance.Status, hubStatus, computed) },
+		func() error { return writeDiff(out, "managed status", "computed status", instance.Status, computed) },
+		func() error {
+			return writeDiff(out, "hub status", "computed hub status", hubStatus,
+				r.MessageRedaction.hubStatus(computed))
+		},
+		func() error {
+			if r.MetadataRules.policyMatchesHub(instance, hubPlc) {
+				_, err := fmt.Fprintln(out, "Spec, labels and annotations: the managed policy matches the hub policy")
+
+				return err
+			}
+
+			desired := r.MetadataRules.desiredPolicy(instance, hubPlc)
+
+			return writeDiff(out, "managed spec", "hub spec", auditContent(instance), auditContent(desired))
+		},
+	}
+
+	for _, section := range sections {
+		if err := section(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeComplianceTable writes the compliance state of the policy and of each policy template in the input managed,
+// hub and computed statuses.
+func writeComplianceTable(out io.Writer, managed, hub, computed policiesv1.PolicyStatus) error {
+	templates := []string{}
+	states := map[string][3]string{}
+
+	for i, status := range []policiesv1.PolicyStatus{managed, hub, computed} {
+		for _, dpt := range status.Details {
+			if dpt == nil {
+				continue
+			}
+
+			name := dpt.TemplateMeta.Name
+			if _, found := states[name]; !found {
+				templates = append(templates, name)
+			}
+
+			templateStates := states[name]
+			templateStates[i] = string(dpt.ComplianceState)
+			states[name] = templateStates
+		}
+	}
+
+	writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+
+	if _, err := fmt.Fprintln(writer, "TEMPLATE\tMANAGED\tHUB\tCOMPUTED"); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(writer, "(policy)\t%s\t%s\t%s\n", orNone(string(managed.ComplianceState)),
+		orNone(string(hub.ComplianceState)), orNone(string(computed.ComplianceState)))
+	if err != nil {
+		return err
+	}
+
+	for _, name := range templates {
+		_, err := fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", name, orNone(states[name][0]), orNone(states[name][1]),
+			orNone(states[name][2]))
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(out)
+
+	return err
+}
+
+// orNone returns "-" for an empty compliance state.
+func orNone(state string) string {
+	if state == "" {
+		return "-"
+	}
+
+	return state
+}
+
+// writeDiff writes a line diff of the YAML representations of the input objects, or that they are identical.
+func writeDiff(out io.Writer, fromName string, toName string, from interface{}, to interface{}) error {
+	fromYAML, err := yaml.Marshal(from)
+	if err != nil {
+		return err
+	}
+
+	toYAML, err := yaml.Marshal(to)
+	if err != nil {
+		return err
+	}
+
+	if string(fromYAML) == string(toYAML) {
+		_, err = fmt.Fprintf(out, "No difference between the %s and the %s\n\n", fromName, toName)
+
+		return err
+	}
+
+	_, err = fmt.Fprintf(out, "--- %s\n+++ %s\n%s\n", fromName, toName,
+		strings.Join(lineDiff(strings.Split(string(fromYAML), "\n"), strings.Split(string(toYAML), "\n")), "\n"))
+
+	return err
+}
+
+// maxLineDiffCells is the maximum size of the table of the longest common subsequence computed by lineDiff. Above it,
+// the changed lines are all reported as removed and then added, instead of being aligned.
+const maxLineDiffCells = 1 << 20
+
+// lineDiff returns the lines of a diff from the input lines to the other input lines, prefixed with "-" when
+// removed, "+" when added and " " when kept. The common prefix and suffix are kept, and the lines in between are
+// aligned on their longest common subsequence.
+func lineDiff(from []string, to []string) []string {
+	prefix := 0
+	for prefix < len(from) && prefix < len(to) && from[prefix] == to[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(from)-prefix && suffix < len(to)-prefix &&
+		from[len(from)-1-suffix] == to[len(to)-1-suffix] {
+		suffix++
+	}
+
+	diff := make([]string, 0, len(from)+len(to))
+
+	for _, line := range from[:prefix] {
+		diff = append(diff, " "+line)
+	}
+
+	diff = append(diff, alignLines(from[prefix:len(from)-suffix], to[prefix:len(to)-suffix])...)
+
+	for _, line := range from[len(from)-suffix:] {
+		diff = append(diff, " "+line)
+	}
+
+	return diff
+}
+
+// alignLines returns the lines of a diff from the input lines to the other input lines based on their longest
+// common subsequence, or all the lines removed and then added if the table of the subsequence would be too large.
+func alignLines(from []string, to []string) []string {
+	diff := []string{}
+
+	if (len(from)+1)*(len(to)+1) > maxLineDiffCells {
+		for _, line := range from {
+			diff = append(diff, "-"+line)
+		}
+
+		for _, line := range to {
+			diff = append(diff, "+"+line)
+		}
+
+		return diff
+	}
+
+	// common[i][j] is the length of the longest common subsequence of from[i:] and to[j:]
+	common := make([][]int, len(from)+1)
+	for i := range common {
+		common[i] = make([]int, len(to)+1)
+	}
+
+	for i := len(from) - 1; i >= 0; i-- {
+		for j := len(to) - 1; j >= 0; j-- {
+			if from[i] == to[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else if common[i+1][j] >= common[i][j+1] {
+				common[i][j] = common[i+1][j]
+			} else {
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+
+	for i < len(from) || j < len(to) {
+		switch {
+		case i < len(from) && j < len(to) && from[i] == to[j]:
+			diff = append(diff, " "+from[i])
+			i++
+			j++
+		case i < len(from) && (j == len(to) || common[i+1][j] >= common[i][j+1]):
+			diff = append(diff, "-"+from[i])
+			i++
+		default:
+			diff = append(diff, "+"+to[j])
+			j++
+		}
+	}
+
+	return diff
+}
@@ -0,0 +1,128 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestLineDiff(t *testing.T) {
+	t.Parallel()
+
+	// lines which are all different and too many to be aligned
+	many := make([]string, 1100)
+	manyOther := make([]string, 1100)
+	manyDiff := []string{" a"}
+
+	for i := range many {
+		many[i] = fmt.Sprintf("from %d", i)
+		manyOther[i] = fmt.Sprintf("to %d", i)
+		manyDiff = append(manyDiff, "-"+many[i])
+	}
+
+	for _, line := range manyOther {
+		manyDiff = append(manyDiff, "+"+line)
+	}
+
+	tests := map[string]struct {
+		from     []string
+		to       []string
+		expected []string
+	}{
+		"aligned": {
+			[]string{"a", "b", "c", "d"}, []string{"a", "c", "e", "d"}, []string{" a", "-b", " c", "+e", " d"},
+		},
+		"identical": {[]string{"a", "b"}, []string{"a", "b"}, []string{" a", " b"}},
+		"appended":  {[]string{"a"}, []string{"a", "b"}, []string{" a", "+b"}},
+		"removed":   {[]string{"a", "b", "a"}, []string{"a", "a"}, []string{" a", "-b", " a"}},
+		"too large": {append([]string{"a"}, many...), append([]string{"a"}, manyOther...), manyDiff},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			diff := lineDiff(test.from, test.to)
+			if !reflect.DeepEqual(diff, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, diff)
+			}
+		})
+	}
+}
+
+func TestInspect(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	if err := policiesv1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build the scheme: %v", err)
+	}
+
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build the scheme: %v", err)
+	}
+
+	managedPlc := &policiesv1.Policy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "managed"},
+		Spec: policiesv1.PolicySpec{
+			PolicyTemplates: []*policiesv1.PolicyTemplate{{
+				ObjectDefinition: runtime.RawExtension{
+					Raw: []byte(`{"apiVersion":"v1","kind":"ConfigurationPolicy","metadata":{"name":"template"}}`),
+				},
+			}},
+		},
+	}
+	hubPlc := managedPlc.DeepCopy()
+	hubPlc.Namespace = "cluster"
+	hubPlc.Spec.RemediationAction = policiesv1.Enforce
+	hubPlc.Status.ComplianceState = policiesv1.Compliant
+
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy.1234", Namespace: "managed"},
+		InvolvedObject: corev1.ObjectReference{
+			Kind: policiesv1.Kind, APIVersion: policiesv1APIVersion, Name: "policy",
+		},
+		Reason:        "policy: managed/template",
+		Message:       "NonCompliant; violation",
+		LastTimestamp: metav1.NewTime(time.Now()),
+	}
+
+	r := &PolicyReconciler{
+		HubClient:             fake.NewClientBuilder().WithScheme(scheme).WithObjects(hubPlc).Build(),
+		ManagedClient:         fake.NewClientBuilder().WithScheme(scheme).WithObjects(managedPlc, event).Build(),
+		ClusterNamespaceOnHub: "cluster",
+	}
+
+	out := &bytes.Buffer{}
+	if err := r.Inspect(context.TODO(), types.NamespacedName{Namespace: "managed", Name: "policy"}, out); err != nil {
+		t.Fatalf("failed to inspect the policy: %v", err)
+	}
+
+	for _, expected := range []string{
+		"1 matching events",
+		"(policy)  -        Compliant  NonCompliant",
+		"template  -        -          NonCompliant",
+		"--- hub status\n+++ computed hub status\n-compliant: Compliant\n+compliant: NonCompliant",
+		"--- managed spec\n+++ hub spec",
+		"+  remediationAction: Enforce",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Fatalf("expected the output to contain %q, got:\n%s", expected, out.String())
+		}
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+	"open-cluster-management.io/governance-policy-propagator/controllers/common"
+)
+
+// MetadataSyncRules determine which label and annotation keys of the managed policy are owned by the hub, and so
+// are synced from the hub policy, and which are local to the managed cluster and preserved. A pattern is either an
+// exact key or a prefix followed by "*", such as "example.com/*".
+//
+// The hub-owned keys that are missing on the hub policy are removed from the managed policy, so that a key removed on
+// the hub is also removed on the managed cluster, unless PreserveMissingHubOwned is set. The keys added on the managed
+// cluster are kept by matching ManagedLocalLabels and ManagedLocalAnnotations, or by not matching the hub-owned keys.
+type MetadataSyncRules struct {
+	// HubOwnedLabels are the label keys synced from the hub policy. All keys are synced when it is empty.
+	HubOwnedLabels []string
+	// ManagedLocalLabels are the label keys preserved on the managed policy, even if they match HubOwnedLabels.
+	ManagedLocalLabels []string
+	// HubOwnedAnnotations are the annotation keys synced from the hub policy. All keys are synced when it is empty.
+	HubOwnedAnnotations []string
+	// ManagedLocalAnnotations are the annotation keys preserved on the managed policy, even if they match
+	// HubOwnedAnnotations.
+	ManagedLocalAnnotations []string
+	// PreserveMissingHubOwned keeps the hub-owned keys of the managed policy that the hub policy doesn't have.
+	PreserveMissingHubOwned bool
+}
+
+func matchesKeyPattern(key string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(key, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		} else if key == pattern {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isSyncedLabel returns whether the label key is owned by the hub.
+func (rules MetadataSyncRules) isSyncedLabel(key string) bool {
+	if matchesKeyPattern(key, rules.ManagedLocalLabels) {
+		return false
+	}
+
+	return len(rules.HubOwnedLabels) == 0 || matchesKeyPattern(key, rules.HubOwnedLabels)
+}
+
+// isSyncedAnnotation returns whether the annotation key is owned by the hub.
+func (rules MetadataSyncRules) isSyncedAnnotation(key string) bool {
+	if matchesKeyPattern(key, rules.ManagedLocalAnnotations) {
+		return false
+	}
+
+	return len(rules.HubOwnedAnnotations) == 0 || matchesKeyPattern(key, rules.HubOwnedAnnotations)
+}
+
+// mergeMetadata returns the hub values of the synced keys and the managed values of the other keys. The synced keys
+// that only the managed policy has are kept unless prune is set. A nil map is returned when there are no keys, to
+// match an object without labels or annotations.
+func mergeMetadata(
+	managed map[string]string, hub map[string]string, isSynced func(string) bool, prune bool,
+) map[string]string {
+	merged := map[string]string{}
+
+	for key, value := range managed {
+		if !prune || !isSynced(key) {
+			merged[key] = value
+		}
+	}
+
+	for key, value := range hub {
+		if isSynced(key) {
+			merged[key] = value
+		}
+	}
+
+	if len(merged) == 0 {
+		return nil
+	}
+
+	return merged
+}
+
+// desiredLabels returns the labels that the managed policy in the input namespace should have.
+func (rules MetadataSyncRules) desiredLabels(
+	managedPlc *policiesv1.Policy, hubPlc *policiesv1.Policy, namespace string,
+) map[string]string {
+	labels := mergeMetadata(
+		managedPlc.GetLabels(), hubPlc.GetLabels(), rules.isSyncedLabel, !rules.PreserveMissingHubOwned,
+	)
+
+	// the cluster namespace label refers to the namespace on the managed cluster
+	if labels[common.ClusterNamespaceLabel] != "" && rules.isSyncedLabel(common.ClusterNamespaceLabel) {
+		labels[common.ClusterNamespaceLabel] = namespace
+	}
+
+	return labels
+}
+
+// desiredAnnotations returns the annotations that the managed policy should have.
+func (rules MetadataSyncRules) desiredAnnotations(
+	managedPlc *policiesv1.Policy, hubPlc *policiesv1.Policy,
+) map[string]string {
+	return mergeMetadata(
+		managedPlc.GetAnnotations(), hubPlc.GetAnnotations(), rules.isSyncedAnnotation, !rules.PreserveMissingHubOwned,
+	)
+}
+
+// desiredPolicy returns a copy of the managed policy with the spec, and the synced labels and annotations, of the hub
+// policy.
+func (rules MetadataSyncRules) desiredPolicy(
+	managedPlc *policiesv1.Policy, hubPlc *policiesv1.Policy,
+) *policiesv1.Policy {
+	desired := managedPlc.DeepCopy()
+	desired.Spec = *hubPlc.Spec.DeepCopy()
+	desired.SetLabels(rules.desiredLabels(managedPlc, hubPlc, managedPlc.GetNamespace()))
+	desired.SetAnnotations(rules.desiredAnnotations(managedPlc, hubPlc))
+
+	return desired
+}
+
+// policyMatchesHub returns whether the spec, and the synced labels and annotations, of the managed policy match the
+// hub policy.
+func (rules MetadataSyncRules) policyMatchesHub(managedPlc *policiesv1.Policy, hubPlc *policiesv1.Policy) bool {
+	desired := rules.desiredPolicy(managedPlc, hubPlc)
+
+	return common.CompareSpecAndAnnotation(managedPlc, desired) &&
+		equality.Semantic.DeepEqual(managedPlc.GetLabels(), desired.GetLabels())
+}
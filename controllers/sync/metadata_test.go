@@ -0,0 +1,212 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+	"open-cluster-management.io/governance-policy-propagator/controllers/common"
+)
+
+func TestDesiredPolicy(t *testing.T) {
+	t.Parallel()
+
+	hubPlc := &policiesv1.Policy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "policy",
+			Namespace: "hub-namespace",
+			Labels: map[string]string{
+				common.ClusterNamespaceLabel: "hub-namespace",
+				common.RootPolicyLabel:       "policies.policy",
+				"hub.example.com/team":       "governance",
+			},
+			Annotations: map[string]string{
+				"policy.open-cluster-management.io/standards": "NIST SP 800-53",
+				"tooling.example.com/owner":                   "hub",
+			},
+		},
+		Spec: policiesv1.PolicySpec{RemediationAction: policiesv1.Inform},
+	}
+
+	managedPlc := &policiesv1.Policy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "policy",
+			Namespace: "managed",
+			Labels: map[string]string{
+				common.ClusterNamespaceLabel: "managed",
+				"local.example.com/on-call":  "team-a",
+			},
+			Annotations: map[string]string{
+				"tooling.example.com/owner": "managed",
+			},
+		},
+		Spec: policiesv1.PolicySpec{RemediationAction: policiesv1.Enforce},
+	}
+
+	tests := map[string]struct {
+		rules               MetadataSyncRules
+		expectedLabels      map[string]string
+		expectedAnnotations map[string]string
+	}{
+		"everything synced and missing keys preserved": {
+			rules: MetadataSyncRules{PreserveMissingHubOwned: true},
+			expectedLabels: map[string]string{
+				common.ClusterNamespaceLabel: "managed",
+				common.RootPolicyLabel:       "policies.policy",
+				"hub.example.com/team":       "governance",
+				"local.example.com/on-call":  "team-a",
+			},
+			expectedAnnotations: map[string]string{
+				"policy.open-cluster-management.io/standards": "NIST SP 800-53",
+				"tooling.example.com/owner":                   "hub",
+			},
+		},
+		"everything synced by default": {
+			rules: MetadataSyncRules{},
+			expectedLabels: map[string]string{
+				common.ClusterNamespaceLabel: "managed",
+				common.RootPolicyLabel:       "policies.policy",
+				"hub.example.com/team":       "governance",
+			},
+			expectedAnnotations: map[string]string{
+				"policy.open-cluster-management.io/standards": "NIST SP 800-53",
+				"tooling.example.com/owner":                   "hub",
+			},
+		},
+		"managed-local keys preserved": {
+			rules: MetadataSyncRules{
+				ManagedLocalLabels:      []string{"local.example.com/*"},
+				ManagedLocalAnnotations: []string{"tooling.example.com/owner"},
+			},
+			expectedLabels: map[string]string{
+				common.ClusterNamespaceLabel: "managed",
+				common.RootPolicyLabel:       "policies.policy",
+				"hub.example.com/team":       "governance",
+				"local.example.com/on-call":  "team-a",
+			},
+			expectedAnnotations: map[string]string{
+				"policy.open-cluster-management.io/standards": "NIST SP 800-53",
+				"tooling.example.com/owner":                   "managed",
+			},
+		},
+		"only hub-owned keys synced": {
+			rules: MetadataSyncRules{
+				HubOwnedLabels:      []string{"policy.open-cluster-management.io/*"},
+				HubOwnedAnnotations: []string{"policy.open-cluster-management.io/*"},
+			},
+			expectedLabels: map[string]string{
+				common.ClusterNamespaceLabel: "managed",
+				common.RootPolicyLabel:       "policies.policy",
+				"local.example.com/on-call":  "team-a",
+			},
+			expectedAnnotations: map[string]string{
+				"policy.open-cluster-management.io/standards": "NIST SP 800-53",
+				"tooling.example.com/owner":                   "managed",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			desired := test.rules.desiredPolicy(managedPlc, hubPlc)
+
+			if !equality.Semantic.DeepEqual(desired.GetLabels(), test.expectedLabels) {
+				t.Errorf("Expected the labels %v, got %v", test.expectedLabels, desired.GetLabels())
+			}
+
+			if !equality.Semantic.DeepEqual(desired.GetAnnotations(), test.expectedAnnotations) {
+				t.Errorf("Expected the annotations %v, got %v", test.expectedAnnotations, desired.GetAnnotations())
+			}
+
+			if desired.Spec.RemediationAction != policiesv1.Inform {
+				t.Errorf("Expected the spec of the hub policy, got %v", desired.Spec)
+			}
+
+			if test.rules.policyMatchesHub(managedPlc, hubPlc) {
+				t.Error("Expected the managed policy to not match the hub policy")
+			}
+
+			if !test.rules.policyMatchesHub(desired, hubPlc) {
+				t.Error("Expected the desired policy to match the hub policy")
+			}
+		})
+	}
+}
+
+func TestPolicyMatchesHubManagedOnlyKeys(t *testing.T) {
+	t.Parallel()
+
+	hubPlc := &policiesv1.Policy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "policy",
+			Namespace:   "hub-namespace",
+			Annotations: map[string]string{"policy.open-cluster-management.io/standards": "NIST SP 800-53"},
+		},
+	}
+
+	managedPlc := hubPlc.DeepCopy()
+	managedPlc.SetNamespace("managed")
+	managedPlc.SetLabels(map[string]string{"local.example.com/on-call": "team-a"})
+	managedPlc.SetAnnotations(map[string]string{
+		"policy.open-cluster-management.io/standards": "NIST SP 800-53",
+		"tooling.example.com/owner":                   "managed",
+	})
+
+	if (MetadataSyncRules{}).policyMatchesHub(managedPlc, hubPlc) {
+		t.Error("Expected the keys that only the managed policy has to be removed by default")
+	}
+
+	if !(MetadataSyncRules{PreserveMissingHubOwned: true}).policyMatchesHub(managedPlc, hubPlc) {
+		t.Error("Expected the keys that only the managed policy has to be preserved")
+	}
+
+	rules := MetadataSyncRules{
+		ManagedLocalLabels: []string{"local.example.com/*"}, ManagedLocalAnnotations: []string{"tooling.example.com/*"},
+	}
+	if !rules.policyMatchesHub(managedPlc, hubPlc) {
+		t.Error("Expected the managed-local keys to be preserved")
+	}
+}
+
+func TestDesiredPolicyKeyRemovedOnHub(t *testing.T) {
+	t.Parallel()
+
+	hubPlc := &policiesv1.Policy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "policy",
+			Namespace:   "hub-namespace",
+			Labels:      map[string]string{"hub.example.com/team": "governance"},
+			Annotations: map[string]string{"policy.open-cluster-management.io/standards": "NIST SP 800-53"},
+		},
+	}
+
+	// the managed policy was synced from the hub policy
+	rules := MetadataSyncRules{}
+	managedPlc := rules.desiredPolicy(&policiesv1.Policy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "managed"},
+	}, hubPlc)
+
+	hubPlc.SetLabels(nil)
+	hubPlc.SetAnnotations(map[string]string{"policy.open-cluster-management.io/categories": "CM"})
+
+	if rules.policyMatchesHub(managedPlc, hubPlc) {
+		t.Fatal("Expected the keys removed on the hub to be a mismatch")
+	}
+
+	desired := rules.desiredPolicy(managedPlc, hubPlc)
+	if len(desired.GetLabels()) != 0 {
+		t.Errorf("Expected the label removed on the hub to be removed, got %v", desired.GetLabels())
+	}
+
+	expected := map[string]string{"policy.open-cluster-management.io/categories": "CM"}
+	if !equality.Semantic.DeepEqual(desired.GetAnnotations(), expected) {
+		t.Errorf("Expected the annotations %v, got %v", expected, desired.GetAnnotations())
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var specDriftGauge = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "policy_status_sync_spec_drift",
+		Help: "Whether the spec, labels or annotations of the managed policy differ from the hub policy (1) or " +
+			"not (0). This is only reported in the status-only mode, since the drift is reverted otherwise.",
+	},
+	[]string{"namespace", "policy"},
+)
+
+var recoveriesCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "policy_status_sync_recoveries_total",
+		Help: "The number of times the managed policy was recreated or reverted from the hub policy.",
+	},
+	[]string{"namespace", "policy", "kind"},
+)
+
+var recoveryFightsCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "policy_status_sync_recovery_fights_total",
+		Help: "The number of times the recovery of the managed policy was held because another actor repeatedly " +
+			"deleted or edited it.",
+	},
+	[]string{"namespace", "policy"},
+)
+
+var dampedHubWritesCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "policy_status_sync_damped_hub_writes_total",
+		Help: "The number of hub status writes that were delayed because only flapping policy templates changed.",
+	},
+	[]string{"namespace", "policy"},
+)
+
+var templateTransitionGauge = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "policy_status_sync_template_last_transition_timestamp_seconds",
+		Help: "The Unix time of the last change of the compliance state of a policy template, which is its " +
+			"current compliance state.",
+	},
+	[]string{"namespace", "policy", "template", "compliance"},
+)
+
+var policyTransitionGauge = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "policy_status_sync_last_transition_timestamp_seconds",
+		Help: "The Unix time of the last change of the overall compliance state of a policy.",
+	},
+	[]string{"namespace", "policy"},
+)
+
+var notificationsCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "policy_status_sync_notifications_total",
+		Help: "The number of compliance change notifications by result: sent, failed after the retries, or " +
+			"dropped because the queue was full.",
+	},
+	[]string{"result"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		specDriftGauge,
+		recoveriesCounter,
+		recoveryFightsCounter,
+		dampedHubWritesCounter,
+		templateTransitionGauge,
+		policyTransitionGauge,
+		notificationsCounter,
+	)
+}
@@ -0,0 +1,99 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"time"
+
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+)
+
+// ComplianceChange is a change of the compliance state of a policy or one of its policy templates.
+type ComplianceChange struct {
+	Namespace string `json:"namespace"`
+	Policy    string `json:"policy"`
+	// Template is the name of the policy template whose compliance state changed, or empty when the overall
+	// compliance state of the policy changed
+	Template      string                     `json:"template,omitempty"`
+	PreviousState policiesv1.ComplianceState `json:"previousState"`
+	State         policiesv1.ComplianceState `json:"state"`
+	// Message is the newest history message of the policy template
+	Message string    `json:"message,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// NotificationSink is notified of the compliance state changes made by the reconciler. Notify is called during the
+// reconcile, so it must not block.
+type NotificationSink interface {
+	Notify(change ComplianceChange)
+}
+
+// complianceStateChanges returns the changes of the compliance states of the policy templates, then of the policy,
+// between the input statuses.
+func complianceStateChanges(
+	instance *policiesv1.Policy, oldStatus policiesv1.PolicyStatus, newStatus policiesv1.PolicyStatus,
+) []ComplianceChange {
+	now := time.Now().UTC()
+	changes := []ComplianceChange{}
+	previousStates := map[string]policiesv1.ComplianceState{}
+
+	for _, dpt := range oldStatus.Details {
+		if dpt != nil {
+			previousStates[dpt.TemplateMeta.Name] = dpt.ComplianceState
+		}
+	}
+
+	for _, dpt := range newStatus.Details {
+		if dpt == nil || dpt.ComplianceState == previousStates[dpt.TemplateMeta.Name] {
+			continue
+		}
+
+		change := ComplianceChange{
+			Namespace:     instance.GetNamespace(),
+			Policy:        instance.GetName(),
+			Template:      dpt.TemplateMeta.Name,
+			PreviousState: previousStates[dpt.TemplateMeta.Name],
+			State:         dpt.ComplianceState,
+			Time:          now,
+		}
+
+		if len(dpt.History) > 0 {
+			change.Message = dpt.History[0].Message
+		}
+
+		changes = append(changes, change)
+	}
+
+	if oldStatus.ComplianceState != newStatus.ComplianceState {
+		changes = append(changes, ComplianceChange{
+			Namespace:     instance.GetNamespace(),
+			Policy:        instance.GetName(),
+			PreviousState: oldStatus.ComplianceState,
+			State:         newStatus.ComplianceState,
+			Time:          now,
+		})
+	}
+
+	return changes
+}
+
+// notifyComplianceChanges notifies the notification sinks of the compliance state changes between the input
+// statuses of the input policy, once the new status is stored on the managed policy.
+func (r *PolicyReconciler) notifyComplianceChanges(
+	instance *policiesv1.Policy, oldStatus policiesv1.PolicyStatus, newStatus policiesv1.PolicyStatus,
+) {
+	if len(r.NotificationSinks) == 0 {
+		return
+	}
+
+	for _, change := range complianceStateChanges(instance, oldStatus, newStatus) {
+		// the notifications leave the managed cluster like the hub status
+		if r.MessageRedaction.KeepFullOnManaged {
+			change.Message = r.MessageRedaction.Redact(change.Message)
+		}
+
+		for _, sink := range r.NotificationSinks {
+			sink.Notify(change)
+		}
+	}
+}
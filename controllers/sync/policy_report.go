@@ -0,0 +1,144 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+)
+
+// PolicyReportGVK is the kind of the reports exported from the policy statuses when ExportPolicyReports is set
+var PolicyReportGVK = schema.GroupVersionKind{Group: "wgpolicyk8s.io", Version: "v1alpha2", Kind: "PolicyReport"}
+
+// policyReportSource is the source of the results in the exported policy reports
+const policyReportSource = "open-cluster-management-policy"
+
+// PolicyReportPolicyLabel is the label on an exported policy report with the name of the policy it was exported from.
+// The report has the same name and namespace as the policy, which owns it so it is garbage collected with the policy.
+const PolicyReportPolicyLabel = "policy.open-cluster-management.io/policy"
+
+// policyReportResult returns the PolicyReport result of a policy template status: pass when Compliant, fail when
+// NonCompliant and skip when the state is not known.
+func policyReportResult(state policiesv1.ComplianceState) string {
+	switch state {
+	case policiesv1.Compliant:
+		return "pass"
+	case policiesv1.NonCompliant:
+		return "fail"
+	default:
+		return "skip"
+	}
+}
+
+// policyReport returns the PolicyReport with a result per policy template in the status of the input policy.
+func policyReport(instance *policiesv1.Policy) *unstructured.Unstructured {
+	report := &unstructured.Unstructured{}
+	report.SetGroupVersionKind(PolicyReportGVK)
+	report.SetName(instance.GetName())
+	report.SetNamespace(instance.GetNamespace())
+	report.SetLabels(map[string]string{PolicyReportPolicyLabel: instance.GetName()})
+	report.SetOwnerReferences([]metav1.OwnerReference{
+		*metav1.NewControllerRef(instance, policiesv1.GroupVersion.WithKind(policiesv1.Kind)),
+	})
+
+	summary := map[string]interface{}{"pass": int64(0), "fail": int64(0), "warn": int64(0), "error": int64(0),
+		"skip": int64(0)}
+	results := []interface{}{}
+
+	for _, dpt := range instance.Status.Details {
+		if dpt == nil {
+			continue
+		}
+
+		outcome := policyReportResult(dpt.ComplianceState)
+		summary[outcome] = summary[outcome].(int64) + 1
+
+		result := map[string]interface{}{
+			"policy": instance.GetName(),
+			"rule":   dpt.TemplateMeta.Name,
+			"result": outcome,
+			"source": policyReportSource,
+			"properties": map[string]interface{}{
+				"complianceState": string(dpt.ComplianceState),
+			},
+		}
+
+		if len(dpt.History) > 0 {
+			result["message"] = dpt.History[0].Message
+			result["timestamp"] = map[string]interface{}{
+				"seconds": dpt.History[0].LastTimestamp.Unix(),
+				"nanos":   int64(0),
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	report.Object["scope"] = map[string]interface{}{
+		"apiVersion": policiesv1.GroupVersion.String(),
+		"kind":       policiesv1.Kind,
+		"name":       instance.GetName(),
+		"namespace":  instance.GetNamespace(),
+		"uid":        string(instance.GetUID()),
+	}
+	report.Object["summary"] = summary
+	report.Object["results"] = results
+
+	return report
+}
+
+// exportPolicyReport creates or updates the PolicyReport exported from the status of the input managed policy. The
+// report is deleted by the garbage collector with the policy.
+func (r *PolicyReconciler) exportPolicyReport(ctx context.Context, instance *policiesv1.Policy) error {
+	desired := policyReport(instance)
+
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(PolicyReportGVK)
+
+	err := r.ManagedClient.Get(
+		ctx, types.NamespacedName{Namespace: desired.GetNamespace(), Name: desired.GetName()}, existing,
+	)
+	if errors.IsNotFound(err) {
+		log.Info("Creating the policy report of the policy",
+			"Request.Namespace", instance.GetNamespace(), "Request.Name", instance.GetName())
+
+		return r.ManagedClient.Create(ctx, desired)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	changed := false
+
+	for _, field := range []string{"scope", "summary", "results"} {
+		if !equality.Semantic.DeepEqual(existing.Object[field], desired.Object[field]) {
+			existing.Object[field] = desired.Object[field]
+			changed = true
+		}
+	}
+
+	if !equality.Semantic.DeepEqual(existing.GetLabels(), desired.GetLabels()) ||
+		!equality.Semantic.DeepEqual(existing.GetOwnerReferences(), desired.GetOwnerReferences()) {
+		existing.SetLabels(desired.GetLabels())
+		existing.SetOwnerReferences(desired.GetOwnerReferences())
+
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	log.V(1).Info("Updating the policy report of the policy",
+		"Request.Namespace", instance.GetNamespace(), "Request.Name", instance.GetName())
+
+	return r.ManagedClient.Update(ctx, existing)
+}
@@ -0,0 +1,68 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+)
+
+func TestPolicyReport(t *testing.T) {
+	t.Parallel()
+
+	instance := &policiesv1.Policy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "managed", UID: "1234"},
+		Status: policiesv1.PolicyStatus{
+			ComplianceState: policiesv1.NonCompliant,
+			Details: []*policiesv1.DetailsPerTemplate{
+				{
+					TemplateMeta:    metav1.ObjectMeta{Name: "compliant-template"},
+					ComplianceState: policiesv1.Compliant,
+					History: []policiesv1.ComplianceHistory{
+						{LastTimestamp: metav1.Unix(1000, 0), Message: "Compliant; notification - ok"},
+					},
+				},
+				{
+					TemplateMeta:    metav1.ObjectMeta{Name: "noncompliant-template"},
+					ComplianceState: policiesv1.NonCompliant,
+				},
+				{
+					TemplateMeta:    metav1.ObjectMeta{Name: "stale-template"},
+					ComplianceState: ComplianceStateUnknown,
+				},
+			},
+		},
+	}
+
+	report := policyReport(instance)
+
+	if report.GetName() != "policy" || report.GetNamespace() != "managed" {
+		t.Fatalf("expected the report to be named after the policy, got %s/%s", report.GetNamespace(), report.GetName())
+	}
+
+	owners := report.GetOwnerReferences()
+	if len(owners) != 1 || owners[0].UID != "1234" || owners[0].Kind != policiesv1.Kind {
+		t.Fatalf("expected the report to be owned by the policy, got %v", owners)
+	}
+
+	expectedSummary := map[string]interface{}{
+		"pass": int64(1), "fail": int64(1), "warn": int64(0), "error": int64(0), "skip": int64(1),
+	}
+	if !equality.Semantic.DeepEqual(report.Object["summary"], expectedSummary) {
+		t.Fatalf("expected the summary %v, got %v", expectedSummary, report.Object["summary"])
+	}
+
+	results := report.Object["results"].([]interface{})
+	if len(results) != 3 {
+		t.Fatalf("expected a result per template, got %v", results)
+	}
+
+	first := results[0].(map[string]interface{})
+	if first["rule"] != "compliant-template" || first["result"] != "pass" ||
+		first["message"] != "Compliant; notification - ok" {
+		t.Fatalf("unexpected result of the compliant template: %v", first)
+	}
+}
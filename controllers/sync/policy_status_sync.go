@@ -7,30 +7,37 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"regexp"
-	"sort"
-	"strconv"
-	"strings"
+	gosync "sync"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/tools/record"
 	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
-	"open-cluster-management.io/governance-policy-propagator/controllers/common"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/ratelimiter"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
-const ControllerName string = "policy-status-sync"
+const (
+	ControllerName string = "policy-status-sync"
+	// resyncJitterFactor spreads the periodic requeues of the policies over up to 10% of the resync period
+	resyncJitterFactor = 0.1
+)
 
 var log = ctrl.Log.WithName(ControllerName)
 
@@ -43,6 +50,10 @@ func (r *PolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
 			handler.EnqueueRequestsFromMapFunc(eventMapper),
 			builder.WithPredicates(eventPredicateFuncs),
 		).
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: r.MaxConcurrentReconciles,
+			RateLimiter:             r.RateLimiter,
+		}).
 		Complete(r)
 }
 
@@ -53,18 +64,109 @@ var _ reconcile.Reconciler = &PolicyReconciler{}
 type PolicyReconciler struct {
 	// This client, initialized using mgr.Client() above, is a split client
 	// that reads objects from the cache and writes to the apiserver
-	HubClient             client.Client
-	ManagedClient         client.Client
+	HubClient     client.Client
+	ManagedClient client.Client
+	// ManagedReader reads the conditions ConfigMaps of the managed policies without caching all the ConfigMaps. It
+	// defaults to ManagedClient.
+	ManagedReader         client.Reader
 	HubRecorder           record.EventRecorder
 	ManagedRecorder       record.EventRecorder
 	Scheme                *runtime.Scheme
 	ClusterNamespaceOnHub string
+	// MaxConcurrentReconciles is the number of policies reconciled in parallel. It defaults to 1.
+	MaxConcurrentReconciles int
+	// RateLimiter limits how frequently requests are requeued. It defaults to the controller-runtime rate limiter.
+	RateLimiter ratelimiter.RateLimiter
+	// ResyncPeriod is the jittered interval at which every policy is reconciled again, regardless of events.
+	// Zero disables the periodic resync.
+	ResyncPeriod time.Duration
+	// HistoryLimit is the number of history entries kept per policy template. It defaults to DefaultHistoryLimit.
+	HistoryLimit int
+	// StatusOnly disables the recovery of the managed policy from the hub policy. Only the status of the managed policy
+	// is updated: it is never recreated, reverted or deleted. Spec drift is reported through an event, a metric and
+	// the SpecInSync condition.
+	StatusOnly bool
+	// MetadataRules determine which labels and annotations are synced from the hub policy and which are preserved on
+	// the managed policy
+	MetadataRules MetadataSyncRules
+	// RecoveryFightThreshold is the number of recoveries of a managed policy within RecoveryFightWindow after which
+	// the controller backs off from recovering it. Only the recreations and the reverts of changes made on the managed
+	// cluster are counted, not the updates from the hub. Zero, the default, disables the detection.
+	RecoveryFightThreshold int
+	// RecoveryFightWindow is the window in which the recoveries are counted and the initial backoff delay
+	RecoveryFightWindow time.Duration
+	// RecoveryBackoffMax is the maximum delay of the exponential backoff from recovering a managed policy
+	RecoveryBackoffMax time.Duration
+	// FieldManager is the field manager of the writes of this controller to the managed policies. It is never reported
+	// as the actor which changed a managed policy.
+	FieldManager string
+	recoveries   recoveryTracker
+	specDrift    specDriftTracker
+	// StaleThreshold is how old the newest event of a policy template can be before its compliance state becomes
+	// Unknown. Zero disables the staleness detection.
+	StaleThreshold time.Duration
+	// StaleThresholdPerKind overrides StaleThreshold for the policy templates of a kind
+	StaleThresholdPerKind map[string]time.Duration
+	// FlapThreshold is the number of compliance state changes of a policy template within FlapWindow after which
+	// the template is reported as flapping. Zero disables the detection.
+	FlapThreshold int
+	// FlapWindow is the window in which the compliance state changes are counted. Zero counts the whole history.
+	FlapWindow time.Duration
+	// FlapDampingPeriod is the minimum interval between hub status writes that are only caused by flapping policy
+	// templates. Zero disables the damping.
+	FlapDampingPeriod time.Duration
+	hubWrites         hubWriteTracker
+	transitions       transitionMetrics
+	syncStates        syncStateTracker
+	conditionStore    conditionTracker
+	// ExportPolicyReports enables the export of the status of every managed policy as a wgpolicyk8s.io PolicyReport
+	// with the same name and namespace
+	ExportPolicyReports bool
+	// NotificationSinks are notified of every compliance state change of a policy or policy template
+	NotificationSinks []NotificationSink
+	// StatusSink writes the computed status of the policies. It defaults to a HubStatusSink with HubClient and
+	// HubRecorder. With a StatusSink, HubClient is optional: without it, the hub is never read and the statuses are
+	// only written to the sink, which requires StatusOnly since the managed policies can't be recovered from the hub.
+	StatusSink StatusSink
+	// AuditLog records the changes made by the controller. Nil disables the audit log.
+	AuditLog *AuditLog
+	// DeletionGracePeriod is how long a policy must be missing on the hub before the managed policy is deleted
+	DeletionGracePeriod time.Duration
+	// MassDeletionThreshold is the share of the policies, between 0 and 1, that may be missing on the hub at the
+	// same time before deletions of managed policies are held. Zero disables the circuit breaker.
+	MassDeletionThreshold float64
+	missingOnHub          missingOnHubTracker
+	// MessageRedaction redacts the history messages in the hub status and in the notifications
+	MessageRedaction MessageRedaction
+	// StatusSizeBudget is the maximum size in bytes of a policy with its status. The status is reduced to fit it.
+	// Zero disables the size guard.
+	StatusSizeBudget int
+	// hubLock is held for reading during a reconcile and for writing when the hub clients are replaced
+	hubLock gosync.RWMutex
+}
+
+// SetHubClients replaces the client and event recorder used to talk to the hub, for example after the hub
+// kubeconfig was rotated. It waits for the reconciles using the previous client and recorder to finish, so after it
+// returns, the previous ones are no longer in use. A StatusSink implementing HubAwareStatusSink is given the new
+// ones as well.
+func (r *PolicyReconciler) SetHubClients(hubClient client.Client, hubRecorder record.EventRecorder) {
+	r.hubLock.Lock()
+	defer r.hubLock.Unlock()
+
+	r.HubClient = traceClient(hubClient, hubCluster)
+	r.HubRecorder = hubRecorder
+
+	if sink, ok := r.StatusSink.(HubAwareStatusSink); ok {
+		sink.SetHubClients(r.HubClient, r.HubRecorder)
+	}
 }
 
 //+kubebuilder:rbac:groups=policy.open-cluster-management.io,resources=policies,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=policy.open-cluster-management.io,resources=policies/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=policy.open-cluster-management.io,resources=policies/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;create;update
 //+kubebuilder:rbac:groups=core,resources=events,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=wgpolicyk8s.io,resources=policyreports,verbs=get;create;update
 // This is required for the status lease for the addon framework
 //+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list
 
@@ -74,17 +176,54 @@ type PolicyReconciler struct {
 // The Controller will requeue the Request to be processed again if the returned error is non-nil or
 // Result.Requeue is true, otherwise upon completion it will remove the work from the queue.
 func (r *PolicyReconciler) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	ctx, span := tracer().Start(ctx, "Reconcile", trace.WithAttributes(
+		attribute.String("k8s.namespace.name", request.Namespace),
+		attribute.String("k8s.object.name", request.Name),
+		attribute.String("hub.namespace.name", r.ClusterNamespaceOnHub),
+	))
+	defer span.End()
+
+	r.syncStates.start(request.NamespacedName)
+
+	result, err := r.reconcile(ctx, request)
+	r.syncStates.finish(request.NamespacedName, result, err)
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else if result.RequeueAfter > 0 {
+		span.SetAttributes(attribute.String("requeue.after", result.RequeueAfter.String()))
+	}
+
+	return result, err
+}
+
+// reconcile syncs the input policy; its outcome is recorded in the sync state of the policy by Reconcile.
+func (r *PolicyReconciler) reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
 	reqLogger := log.WithValues(
 		"Request.Namespace", request.Namespace, "Request.Name", request.Name, "HubNamespace", r.ClusterNamespaceOnHub,
 	)
 	reqLogger.Info("Reconciling the policy")
 
+	r.hubLock.RLock()
+	defer r.hubLock.RUnlock()
+
 	// Fetch the Policy instance
 	instance := &policiesv1.Policy{}
 
 	err := r.ManagedClient.Get(ctx, request.NamespacedName, instance)
 	if err != nil {
 		if errors.IsNotFound(err) {
+			r.specDrift.forget(request.NamespacedName)
+			r.transitions.forget(request.NamespacedName)
+			if r.sinkOnly() {
+				reqLogger.Info("Policy was deleted, no status to update")
+				r.hubWrites.forget(request.NamespacedName)
+				r.syncStates.forget(request.NamespacedName)
+				r.conditionStore.forget(request.NamespacedName)
+
+				return reconcile.Result{}, nil
+			}
 			// The replicated policy on the managed cluster was deleted.
 			// check if it was deleted by user by checking if it still exists on hub
 			hubInstance := &policiesv1.Policy{}
@@ -96,6 +235,10 @@ func (r *PolicyReconciler) Reconcile(ctx context.Context, request reconcile.Requ
 				if errors.IsNotFound(err) {
 					// confirmed deleted on hub, doing nothing
 					reqLogger.Info("Policy was deleted, no status to update")
+					r.recoveries.forget(request.NamespacedName)
+					r.hubWrites.forget(request.NamespacedName)
+					r.syncStates.forget(request.NamespacedName)
+					r.conditionStore.forget(request.NamespacedName)
 
 					return reconcile.Result{}, nil
 				}
@@ -105,18 +248,61 @@ func (r *PolicyReconciler) Reconcile(ctx context.Context, request reconcile.Requ
 				return reconcile.Result{}, err
 			}
 
+			if r.StatusOnly {
+				reqLogger.Info("The managed policy was deleted but still exists on the hub, not recovering it " +
+					"in the status-only mode")
+
+				return reconcile.Result{}, nil
+			}
+
+			if remaining := r.recoveries.backoffRemaining(request.NamespacedName); remaining > 0 {
+				reqLogger.Info("Backing off from recreating the managed policy", "remaining", remaining.String())
+
+				return reconcile.Result{RequeueAfter: remaining}, nil
+			}
+
 			// still exist on hub, recover policy on managed
 			managedInstance := hubInstance.DeepCopy()
 			managedInstance.Namespace = request.Namespace
+			managedInstance.SetLabels(r.MetadataRules.desiredLabels(
+				&policiesv1.Policy{}, hubInstance, request.Namespace,
+			))
+			managedInstance.SetAnnotations(r.MetadataRules.desiredAnnotations(&policiesv1.Policy{}, hubInstance))
+			managedInstance.SetOwnerReferences(nil)
+			managedInstance.SetResourceVersion("")
+
+			err = r.ManagedClient.Create(ctx, managedInstance)
+			if err != nil {
+				reqLogger.Error(err, "Failed to recreate the managed policy, will requeue the request")
+
+				return reconcile.Result{}, err
+			}
+
+			// the conditions ConfigMap was deleted with the previous managed policy
+			r.conditionStore.forget(request.NamespacedName)
+
+			err = r.patchConditions(ctx, managedInstance, hubReachableCondition(nil), metav1.Condition{
+				Type:    ConditionSpecInSync,
+				Status:  metav1.ConditionTrue,
+				Reason:  "Recreated",
+				Message: "The managed policy was recreated from the hub policy",
+			})
+			if err != nil {
+				reqLogger.Error(err, "Failed to set the conditions on the managed policy, will requeue the request")
 
-			if managedInstance.Labels["policy.open-cluster-management.io/cluster-namespace"] != "" {
-				managedInstance.Labels["policy.open-cluster-management.io/cluster-namespace"] = request.Namespace
+				return reconcile.Result{}, err
 			}
 
-			managedInstance.SetOwnerReferences(nil)
-			managedInstance.SetResourceVersion("")
+			r.audit(managedInstance, AuditRecord{
+				Action: AuditManagedCreated,
+				Reason: "The managed policy was deleted but still exists on the hub",
+				After:  auditContent(managedInstance),
+			})
 
-			return reconcile.Result{}, r.ManagedClient.Create(ctx, managedInstance)
+			// the deletion isn't recorded in the managed fields, so the actor is unknown
+			backoff := r.recordRecovery(managedInstance, recoveryRecreate, unknownActor, true)
+
+			return reconcile.Result{RequeueAfter: backoff}, nil
 		}
 		// Error reading the object - requeue the request.
 		reqLogger.Error(err, "Error reading the policy object, will requeue the request")
@@ -125,17 +311,43 @@ func (r *PolicyReconciler) Reconcile(ctx context.Context, request reconcile.Requ
 	}
 	// get hub policy
 	hubPlc := &policiesv1.Policy{}
-	err = r.HubClient.Get(ctx, types.NamespacedName{Namespace: r.ClusterNamespaceOnHub, Name: request.Name}, hubPlc)
+	if r.sinkOnly() {
+		hubPlc = r.sinkPolicy(instance)
+	} else {
+		err = r.HubClient.Get(ctx, types.NamespacedName{Namespace: r.ClusterNamespaceOnHub, Name: request.Name}, hubPlc)
+	}
 
 	if err != nil {
 		// hub policy not found, it has been deleted
 		if errors.IsNotFound(err) {
 			reqLogger.Info("Hub policy not found, it has been deleted")
+
+			if r.StatusOnly {
+				reqLogger.Info("Not deleting the managed policy in the status-only mode")
+
+				return reconcile.Result{}, nil
+			}
+
+			requeueAfter, err := r.holdDeletion(ctx, instance)
+			if err != nil || requeueAfter > 0 {
+				return reconcile.Result{RequeueAfter: requeueAfter}, err
+			}
 			// try to delete local one
 			err = r.ManagedClient.Delete(ctx, instance)
 			if err == nil || errors.IsNotFound(err) {
 				// no err or err is not found means local policy has been deleted
 				reqLogger.Info("Managed policy was deleted")
+				r.audit(instance, AuditRecord{
+					Action: AuditManagedDeleted,
+					Reason: "The policy was deleted on the hub",
+					Before: auditContent(instance),
+				})
+				r.missingOnHub.forget(request.NamespacedName)
+				r.recoveries.forget(request.NamespacedName)
+				r.hubWrites.forget(request.NamespacedName)
+				r.transitions.forget(request.NamespacedName)
+				r.syncStates.forget(request.NamespacedName)
+				r.conditionStore.forget(request.NamespacedName)
 
 				return reconcile.Result{}, nil
 			}
@@ -146,217 +358,141 @@ func (r *PolicyReconciler) Reconcile(ctx context.Context, request reconcile.Requ
 		}
 
 		reqLogger.Error(err, "Failed to get policy on hub")
+		r.reportFailure(ctx, instance, hubReachableCondition(err))
 
 		return reconcile.Result{}, err
 	}
-	// found, ensure managed plc matches hub plc
-	if !common.CompareSpecAndAnnotation(instance, hubPlc) {
-		// plc mismatch, update to latest
-		instance.SetAnnotations(hubPlc.GetAnnotations())
-		instance.Spec = hubPlc.Spec
-		// update and stop here
-		reqLogger.Info("Found mismatch with hub and managed policies, updating")
-
-		return reconcile.Result{}, r.ManagedClient.Update(ctx, instance)
-	}
-
-	// plc matches hub plc, then get events
-	eventList := &corev1.EventList{}
-	err = r.ManagedClient.List(ctx, eventList, client.InNamespace(instance.GetNamespace()))
 
-	if err != nil {
-		// there is an error to list events, requeue
-		reqLogger.Error(err, "Error listing events, will requeue the request")
-
-		return reconcile.Result{}, err
+	r.missingOnHub.forget(request.NamespacedName)
+	// a revert after an update of the hub policy isn't counted as a fight with another actor
+	hubChanged := r.recoveries.observeHub(request.NamespacedName, hubPlc)
+	// the conditions are set on the managed policy at the end of the reconcile
+	conditions := []metav1.Condition{}
+	if !r.sinkOnly() {
+		conditions = append(conditions, hubReachableCondition(nil))
 	}
-	// filter events to current policy instance and build map
-	eventForPolicyMap := make(map[string]*[]historyEvent)
-	// panic if regexp invalid
-	rgx := regexp.MustCompile(`(?i)^policy:\s*([A-Za-z0-9.-]+)\s*\/([A-Za-z0-9.-]+)`)
-	for _, event := range eventList.Items {
-		// sample event.Reason -- reason: 'policy: calamari/policy-grc-rbactest-example'
-		reason := rgx.FindString(event.Reason)
-		if event.InvolvedObject.Kind == policiesv1.Kind && event.InvolvedObject.APIVersion == policiesv1APIVersion &&
-			event.InvolvedObject.Name == instance.GetName() && reason != "" {
-			templateName := rgx.FindStringSubmatch(event.Reason)[2]
-			eventHistory := historyEvent{
-				ComplianceHistory: policiesv1.ComplianceHistory{
-					LastTimestamp: event.LastTimestamp,
-					Message: strings.TrimSpace(strings.TrimPrefix(
-						event.Message, "(combined from similar events):")),
-					EventName: event.GetName(),
-				},
-				eventTime: *event.EventTime.DeepCopy(),
-			}
-
-			if eventForPolicyMap[templateName] == nil {
-				eventForPolicyMap[templateName] = &[]historyEvent{}
-			}
-
-			templateEvents := append(*eventForPolicyMap[templateName], eventHistory)
-			eventForPolicyMap[templateName] = &templateEvents
+	// found, ensure managed plc matches hub plc
+	if r.StatusOnly {
+		// report the drift without reverting it and continue with the status sync
+		conditions = append(conditions, r.reportSpecDrift(instance, hubPlc))
+	} else if !r.MetadataRules.policyMatchesHub(instance, hubPlc) {
+		if remaining := r.recoveries.backoffRemaining(request.NamespacedName); remaining > 0 {
+			reqLogger.Info("Found mismatch with hub and managed policies, backing off from updating",
+				"remaining", remaining.String())
+
+			r.reportFailure(ctx, instance, append(conditions, metav1.Condition{
+				Type:    ConditionSpecInSync,
+				Status:  metav1.ConditionFalse,
+				Reason:  "RecoveryBackoff",
+				Message: "The managed policy differs from the hub policy but is repeatedly changed by another actor",
+			})...)
+
+			return reconcile.Result{RequeueAfter: remaining}, nil
 		}
-	}
 
-	oldStatus := *instance.Status.DeepCopy()
-	newStatus := policiesv1.PolicyStatus{}
-
-	reqLogger.Info("Updating status for policy templates")
+		actor := lastSpecWriter(instance, r.FieldManager)
+		before := auditContent(instance.DeepCopy())
+		// plc mismatch, update to latest while preserving the labels and annotations local to the managed cluster
+		desired := r.MetadataRules.desiredPolicy(instance, hubPlc)
+		instance.SetLabels(desired.GetLabels())
+		instance.SetAnnotations(desired.GetAnnotations())
+		instance.Spec = desired.Spec
+		// update and stop here
+		reqLogger.Info("Found mismatch with hub and managed policies, updating", "actor", actor)
 
-	for _, policyT := range instance.Spec.PolicyTemplates {
-		object, _, err := unstructured.UnstructuredJSONScheme.Decode(policyT.ObjectDefinition.Raw, nil, nil)
+		err = r.ManagedClient.Update(ctx, instance)
 		if err != nil {
-			// failed to decode PolicyTemplate, skipping it
-			reqLogger.Error(err, "Failed to decode policy template, skipping it")
+			reqLogger.Error(err, "Failed to update the managed policy, will requeue the request")
 
-			break
+			return reconcile.Result{}, err
 		}
 
-		tName := object.(metav1.Object).GetName()
-		existingDpt := &policiesv1.DetailsPerTemplate{}
-		// retrieve existingDpt from instance.status.details field
-		found := false
-
-		for _, dpt := range instance.Status.Details {
-			if dpt.TemplateMeta.Name == tName {
-				// found existing status for policyTemplate
-				// retrieve it
-				existingDpt = dpt
-				found = true
-
-				reqLogger.Info("Found existing status, retrieving it", "PolicyTemplate", tName)
-
-				break
-			}
-		}
-		// no dpt from status field, initialize it
-		if !found {
-			existingDpt = &policiesv1.DetailsPerTemplate{
-				TemplateMeta: metav1.ObjectMeta{
-					Name: tName,
-				},
-				History: []policiesv1.ComplianceHistory{},
-			}
-		}
+		err = r.patchConditions(ctx, instance, append(conditions, metav1.Condition{
+			Type:    ConditionSpecInSync,
+			Status:  metav1.ConditionTrue,
+			Reason:  "Reverted",
+			Message: "The managed policy was reverted to the hub policy after a change by " + actor,
+		})...)
+		if err != nil {
+			reqLogger.Error(err, "Failed to set the conditions on the managed policy, will requeue the request")
 
-		history := []historyEvent{}
-		if eventForPolicyMap[tName] != nil {
-			history = *eventForPolicyMap[tName]
+			return reconcile.Result{}, err
 		}
 
-		for _, ech := range existingDpt.History {
-			exists := false
-
-			for _, ch := range history {
-				if ch.LastTimestamp.Time.Equal(ech.LastTimestamp.Time) && ch.EventName == ech.EventName {
-					// do nothing
-					exists = true
+		r.audit(instance, AuditRecord{
+			Action: AuditManagedReverted,
+			Reason: "The managed policy differed from the hub policy",
+			Actor:  actor,
+			Before: before,
+			After:  auditContent(instance),
+		})
 
-					break
-				}
-			}
-			// doesn't exist, append to history
-			if !exists {
-				history = append(history, historyEvent{ComplianceHistory: ech})
-			}
+		if backoff := r.recordRecovery(instance, recoveryRevert, actor, !hubChanged); backoff > 0 {
+			return reconcile.Result{RequeueAfter: backoff}, nil
 		}
-		// sort by lasttimestamp, break ties with EventTime (if present) or EventName
-		sort.Slice(history, func(i, j int) bool {
-			if history[i].LastTimestamp.Equal(&history[j].LastTimestamp) {
-				if !history[i].eventTime.IsZero() && !history[j].eventTime.IsZero() {
-					reqLogger.V(2).Info("Event timestamp collision, order determined by EventTime",
-						"event1Name", history[i].EventName, "event2Name", history[j].EventName)
-
-					return !history[i].eventTime.Before(&history[j].eventTime)
-				}
-				// Timestamps are the same: attempt to use the event name.
-				// Conventionally (in client-go), the event name has a hexadecimal
-				// nanosecond timestamp as a suffix after a period.
-				iNameParts := strings.Split(history[i].EventName, ".")
-				jNameParts := strings.Split(history[j].EventName, ".")
-				errMsg := "Unable to interpret hexadecimal timestamp in event name, " +
-					"can't guarantee ordering of events in this status"
-
-				iNanos, err := strconv.ParseInt(iNameParts[len(iNameParts)-1], 16, 64)
-				if err != nil {
-					reqLogger.Error(err, errMsg, "eventName", history[i].EventName)
-
-					return false
-				}
 
-				jNanos, err := strconv.ParseInt(jNameParts[len(jNameParts)-1], 16, 64)
-				if err != nil {
-					reqLogger.Error(err, errMsg, "eventName", history[j].EventName)
+		return r.resyncResult(), nil
+	}
 
-					return false
-				}
+	if hubPlc.Spec.Disabled {
+		return r.syncDisabled(ctx, request.NamespacedName, instance, hubPlc, conditions)
+	}
 
-				reqLogger.V(2).Info("Event timestamp collision, order determined by hex timestamp in name",
-					"event1Name", history[i].EventName, "event2Name", history[j].EventName)
+	currentConditions, err := r.conditions(ctx, instance)
+	if err != nil {
+		reqLogger.Error(err, "Failed to get the conditions of the managed policy, will requeue the request")
 
-				return iNanos > jNanos
-			}
+		return reconcile.Result{}, err
+	}
 
-			return !history[i].LastTimestamp.Time.Before(history[j].LastTimestamp.Time)
-		})
-		// remove duplicates
-		newHistory := []policiesv1.ComplianceHistory{}
+	enabled, enabledSince := enabledCondition(currentConditions)
+	if enabled != nil {
+		conditions = append(conditions, *enabled)
+	}
+
+	// plc matches hub plc, then get events
+	eventList := &corev1.EventList{}
+	err = r.ManagedClient.List(ctx, eventList, client.InNamespace(instance.GetNamespace()))
 
-		for historyIndex := 0; historyIndex < len(history); historyIndex++ {
-			newHistory = append(newHistory, history[historyIndex].ComplianceHistory)
+	if err != nil {
+		// there is an error to list events, requeue
+		reqLogger.Error(err, "Error listing events, will requeue the request")
 
-			for j := historyIndex; j < len(history); j++ {
-				// Skip over duplicate statuses where the event name and message match the current status
-				if history[historyIndex].EventName != history[j].EventName ||
-					history[historyIndex].Message != history[j].Message {
-					historyIndex = j - 1
+		return reconcile.Result{}, err
+	}
+	// filter events to current policy instance and build map
+	eventForPolicyMap := policyEvents(instance, eventList.Items, enabledSince)
 
-					break
-				}
-			}
-		}
-		// shorten it to first 10
-		size := 10
-		if len(newHistory) < 10 {
-			size = len(newHistory)
-		}
+	oldStatus := *instance.Status.DeepCopy()
 
-		existingDpt.History = newHistory[0:size]
+	reqLogger.Info("Updating status for policy templates")
 
-		// set compliancy at different level
-		if len(existingDpt.History) > 0 {
-			if strings.HasPrefix(strings.ToLower(strings.TrimSpace(
-				strings.TrimPrefix(existingDpt.History[0].Message, "(combined from similar events):"))), "compliant") {
-				existingDpt.ComplianceState = policiesv1.Compliant
-			} else {
-				existingDpt.ComplianceState = policiesv1.NonCompliant
-			}
-		}
+	var staleRecheck, dampedFor time.Duration
 
-		// append existingDpt to status
-		newStatus.Details = append(newStatus.Details, existingDpt)
+	instance.Status, staleRecheck = r.computeStatus(instance, eventForPolicyMap, reqLogger)
+	instance.Status = r.MessageRedaction.managedStatus(instance.Status, oldStatus)
 
-		reqLogger.Info("Status update complete", "PolicyTemplate", tName)
+	reduction, size := r.fitStatus(instance, hubPlc)
+	if reduction != nil {
+		reqLogger.Info("Reduced the status to fit the status size budget", "budget", r.StatusSizeBudget,
+			"size", size, "reduction", reduction.String())
 	}
 
-	instance.Status = newStatus
-	// one violation found in status of one template, set overall compliancy to NonCompliant
-	isCompliant := true
+	newStatus := instance.Status
+	// the status written to the hub, whose messages are always redacted
+	redactedStatus := r.MessageRedaction.hubStatus(newStatus)
 
-	for _, dpt := range newStatus.Details {
-		if dpt.ComplianceState == "NonCompliant" {
-			instance.Status.ComplianceState = policiesv1.NonCompliant
-			isCompliant = false
+	r.syncStates.observeStatus(request.NamespacedName, eventCount(eventForPolicyMap), newStatus)
 
-			break
-		} else if dpt.ComplianceState == "" {
-			isCompliant = false
-		}
+	conditions = append(conditions, complianceCondition(newStatus))
+
+	if r.StatusSizeBudget > 0 {
+		conditions = append(conditions, r.statusSizeCondition(reduction, size))
 	}
-	// set to compliant only when all templates are compliant
-	if isCompliant {
-		instance.Status.ComplianceState = policiesv1.Compliant
+
+	flapping := r.flappingTemplates(newStatus)
+	if flapping != nil {
+		conditions = append(conditions, r.flappingCondition(flapping))
 	}
 
 	// all done, update status on managed and hub
@@ -369,6 +505,8 @@ func (r *PolicyReconciler) Reconcile(ctx context.Context, request reconcile.Requ
 
 		if err != nil {
 			reqLogger.Error(err, "Failed to get update policy status on managed")
+			r.reportFailure(ctx, instance, append(conditions,
+				syncedCondition("ManagedStatusUpdateFailed", "", err))...)
 
 			return reconcile.Result{}, err
 		}
@@ -376,35 +514,134 @@ func (r *PolicyReconciler) Reconcile(ctx context.Context, request reconcile.Requ
 		r.ManagedRecorder.Event(instance, "Normal", "PolicyStatusSync",
 			fmt.Sprintf("Policy %s status was updated in cluster namespace %s", instance.GetName(),
 				instance.GetNamespace()))
+		r.audit(instance, AuditRecord{
+			Action: AuditManagedStatusUpdated,
+			Reason: "The status was computed from the events of the policy templates",
+			Events: historyEvents(oldStatus, newStatus),
+			Before: oldStatus,
+			After:  newStatus,
+		})
+
+		r.notifyComplianceChanges(instance, oldStatus, newStatus)
 	} else {
 		reqLogger.Info("status match on managed, nothing to update")
 	}
 
-	if os.Getenv("ON_MULTICLUSTERHUB") != "true" && !equality.Semantic.DeepEqual(hubPlc.Status, instance.Status) {
+	onHub := os.Getenv("ON_MULTICLUSTERHUB") == "true"
+
+	var hubStatus policiesv1.PolicyStatus
+
+	if !onHub {
+		hubStatus, err = r.readHubStatus(ctx, instance, hubPlc, conditions)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	if onHub {
+		reqLogger.Info("status match on hub, nothing to update")
+
+		conditions = append(conditions, syncedCondition(
+			"OnHub", "The managed cluster is the hub cluster, so the status is not synced", nil,
+		))
+	} else if equality.Semantic.DeepEqual(hubStatus, redactedStatus) {
+		reqLogger.Info("status match on hub, nothing to update")
+
+		// keep the reason of the last sync unless the previous sync failed, while still recording the sync time
+		if synced := meta.FindStatusCondition(currentConditions, ConditionSynced); synced != nil &&
+			synced.Status == metav1.ConditionTrue {
+			conditions = append(conditions, *synced)
+		} else {
+			conditions = append(conditions, syncedCondition(
+				"HubStatusMatched", "The status on the hub matches the managed policy", nil,
+			))
+		}
+	} else if dampedFor = r.dampHubWrite(
+		request.NamespacedName, hubStatus, redactedStatus, flapping,
+	); dampedFor > 0 {
+		reqLogger.Info("Damping the hub status update of flapping policy templates",
+			"flapping", flapping, "remaining", dampedFor.String())
+		dampedHubWritesCounter.WithLabelValues(instance.GetNamespace(), instance.GetName()).Inc()
+
+		conditions = append(conditions, metav1.Condition{
+			Type:   ConditionSynced,
+			Status: metav1.ConditionFalse,
+			Reason: "HubStatusDamped",
+			Message: fmt.Sprintf("The status of flapping policy templates is synced to the hub at most every %s",
+				r.FlapDampingPeriod),
+		})
+	} else {
 		reqLogger.Info("status not in sync, update the hub")
 
-		hubPlc.Status = instance.Status
-		err = r.HubClient.Status().Update(ctx, hubPlc)
+		hubPlc.Status = redactedStatus
+		err = r.statusSink().WriteStatus(ctx, hubPlc)
 
 		if err != nil {
 			reqLogger.Error(err, "Failed to get update policy status on hub")
+			r.reportFailure(ctx, instance, append(conditions,
+				syncedCondition("HubStatusUpdateFailed", "", err))...)
 
 			return reconcile.Result{}, err
 		}
 
-		r.HubRecorder.Event(hubPlc, "Normal", "PolicyStatusSync",
-			fmt.Sprintf("Policy %s status was updated in cluster namespace %s", hubPlc.GetName(),
-				hubPlc.GetNamespace()))
-	} else {
-		reqLogger.Info("status match on hub, nothing to update")
+		r.hubWrites.record(request.NamespacedName)
+		r.audit(instance, AuditRecord{
+			Action: AuditHubStatusUpdated,
+			Reason: "The status on the hub differed from the managed policy",
+			Events: historyEvents(hubStatus, redactedStatus),
+			Before: hubStatus,
+			After:  redactedStatus,
+		})
+
+		conditions = append(conditions, syncedCondition(
+			"HubStatusUpdated", "The status was synced to the hub", nil,
+		))
+	}
+
+	err = r.patchConditions(ctx, instance, conditions...)
+	if err != nil {
+		reqLogger.Error(err, "Failed to set the conditions on the managed policy, will requeue the request")
+
+		return reconcile.Result{}, err
+	}
+
+	currentConditions, err = r.conditions(ctx, instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	r.transitions.report(request.NamespacedName, instance, currentConditions)
+
+	if r.ExportPolicyReports {
+		if err := r.exportPolicyReport(ctx, instance); err != nil {
+			reqLogger.Error(err, "Failed to export the policy report, will requeue the request")
+
+			return reconcile.Result{}, err
+		}
 	}
 
 	reqLogger.Info("Reconciling complete")
 
-	return reconcile.Result{}, nil
+	result := r.resyncResult()
+	if staleRecheck > 0 && (result.RequeueAfter == 0 || staleRecheck < result.RequeueAfter) {
+		// no event is expected when a template becomes stale, so reconcile again at that time
+		result.RequeueAfter = staleRecheck
+	}
+
+	if dampedFor > 0 && (result.RequeueAfter == 0 || dampedFor < result.RequeueAfter) {
+		// sync the newest state of the flapping templates once the damping period elapses
+		result.RequeueAfter = dampedFor
+	}
+
+	return result, nil
 }
 
-type historyEvent struct {
-	policiesv1.ComplianceHistory
-	eventTime metav1.MicroTime
+// resyncResult returns the result of a successful reconcile of an existing policy. When a resync period is set,
+// the policy is requeued after a jittered resync period so that missed events are eventually reconciled.
+func (r *PolicyReconciler) resyncResult() reconcile.Result {
+	if r.ResyncPeriod <= 0 {
+		return reconcile.Result{}
+	}
+
+	return reconcile.Result{RequeueAfter: wait.Jitter(r.ResyncPeriod, resyncJitterFactor)}
 }
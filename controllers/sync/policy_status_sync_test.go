@@ -0,0 +1,26 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResyncResult(t *testing.T) {
+	t.Parallel()
+
+	r := &PolicyReconciler{}
+	if result := r.resyncResult(); result.RequeueAfter != 0 || result.Requeue {
+		t.Fatalf("expected no requeue without a resync period, got %+v", result)
+	}
+
+	r.ResyncPeriod = 10 * time.Minute
+
+	for i := 0; i < 100; i++ {
+		result := r.resyncResult()
+		if result.RequeueAfter < r.ResyncPeriod || result.RequeueAfter > 11*time.Minute {
+			t.Fatalf("expected a requeue within 10%% after the resync period, got %s", result.RequeueAfter)
+		}
+	}
+}
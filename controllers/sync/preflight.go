@@ -0,0 +1,180 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	authorizationv1client "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+)
+
+// Permission is an access that the controller requires on a cluster.
+type Permission struct {
+	Group       string
+	Resource    string
+	Subresource string
+	Verb        string
+	Namespace   string
+}
+
+func (p Permission) String() string {
+	resource := p.Resource
+	if p.Subresource != "" {
+		resource += "/" + p.Subresource
+	}
+
+	if p.Group != "" {
+		resource += "." + p.Group
+	}
+
+	return fmt.Sprintf("%s %s in namespace %s", p.Verb, resource, p.Namespace)
+}
+
+// PermissionResult is the result of the SelfSubjectAccessReview of a Permission.
+type PermissionResult struct {
+	Permission
+	Allowed bool
+	// Reason explains why the permission is denied, or contains the error of the review
+	Reason string
+}
+
+// HubPermissions returns the permissions that the controller requires in the cluster namespace on the hub. The
+// policies are listed by the hub-policies readiness check and the mass deletion circuit breaker.
+func HubPermissions(clusterNamespaceOnHub string) []Permission {
+	group := policiesv1.SchemeGroupVersion.Group
+
+	return []Permission{
+		{Group: group, Resource: "policies", Verb: "get", Namespace: clusterNamespaceOnHub},
+		{Group: group, Resource: "policies", Verb: "list", Namespace: clusterNamespaceOnHub},
+		{Group: group, Resource: "policies", Subresource: "status", Verb: "update", Namespace: clusterNamespaceOnHub},
+		{Resource: "events", Verb: "create", Namespace: clusterNamespaceOnHub},
+	}
+}
+
+// ManagedPermissions returns the permissions that the controller requires in the input namespace on the managed
+// cluster. With LeasePermissions and PolicyReportPermissions, they must be kept in sync with the kubebuilder RBAC
+// markers in policy_status_sync.go.
+func ManagedPermissions(namespace string) []Permission {
+	group := policiesv1.SchemeGroupVersion.Group
+	permissions := []Permission{}
+
+	for _, verb := range []string{"get", "list", "watch", "create", "update", "patch", "delete"} {
+		permissions = append(permissions, Permission{
+			Group: group, Resource: "policies", Verb: verb, Namespace: namespace,
+		})
+	}
+
+	for _, verb := range []string{"get", "update", "patch"} {
+		permissions = append(permissions, Permission{
+			Group: group, Resource: "policies", Subresource: "status", Verb: verb, Namespace: namespace,
+		})
+	}
+
+	permissions = append(permissions, Permission{
+		Group: group, Resource: "policies", Subresource: "finalizers", Verb: "update", Namespace: namespace,
+	})
+
+	for _, verb := range []string{"get", "create", "update"} {
+		permissions = append(permissions, Permission{Resource: "configmaps", Verb: verb, Namespace: namespace})
+	}
+
+	for _, verb := range []string{"get", "list", "watch", "create", "update", "patch", "delete"} {
+		permissions = append(permissions, Permission{Resource: "events", Verb: verb, Namespace: namespace})
+	}
+
+	return permissions
+}
+
+// LeasePermissions returns the permissions that the controller additionally requires in the input namespace of the
+// controller on the managed cluster to report its status through the addon lease, which checks the addon pods.
+func LeasePermissions(namespace string) []Permission {
+	return []Permission{
+		{Resource: "pods", Verb: "get", Namespace: namespace},
+		{Resource: "pods", Verb: "list", Namespace: namespace},
+	}
+}
+
+// PolicyReportPermissions returns the permissions that the controller additionally requires in the input namespace
+// on the managed cluster to export the policy reports.
+func PolicyReportPermissions(namespace string) []Permission {
+	permissions := []Permission{}
+
+	for _, verb := range []string{"get", "create", "update"} {
+		permissions = append(permissions, Permission{
+			Group: PolicyReportGVK.Group, Resource: "policyreports", Verb: verb, Namespace: namespace,
+		})
+	}
+
+	return permissions
+}
+
+// CheckPermissions runs a SelfSubjectAccessReview for each input permission and returns the results in the same
+// order.
+func CheckPermissions(
+	ctx context.Context, ssarClient authorizationv1client.SelfSubjectAccessReviewInterface, permissions []Permission,
+) []PermissionResult {
+	results := make([]PermissionResult, 0, len(permissions))
+
+	for _, permission := range permissions {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace:   permission.Namespace,
+					Verb:        permission.Verb,
+					Group:       permission.Group,
+					Resource:    permission.Resource,
+					Subresource: permission.Subresource,
+				},
+			},
+		}
+
+		result := PermissionResult{Permission: permission}
+
+		review, err := ssarClient.Create(ctx, review, metav1.CreateOptions{})
+		if err != nil {
+			result.Reason = fmt.Sprintf("the access review failed: %v", err)
+		} else {
+			result.Allowed = review.Status.Allowed
+			result.Reason = review.Status.Reason
+
+			if !result.Allowed && result.Reason == "" {
+				result.Reason = review.Status.EvaluationError
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// WritePermissionReport writes a pass/fail line for each result of the input cluster and returns whether all the
+// permissions are allowed.
+func WritePermissionReport(w io.Writer, cluster string, results []PermissionResult) (bool, error) {
+	passed := true
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	if _, err := fmt.Fprintf(tw, "Permissions on the %s cluster:\n", cluster); err != nil {
+		return false, err
+	}
+
+	for _, result := range results {
+		status := "PASS"
+		if !result.Allowed {
+			status = "FAIL"
+			passed = false
+		}
+
+		if _, err := fmt.Fprintf(tw, "  [%s]\t%s\t%s\n", status, result.Permission, result.Reason); err != nil {
+			return false, err
+		}
+	}
+
+	return passed, tw.Flush()
+}
@@ -0,0 +1,115 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestCheckPermissions(t *testing.T) {
+	t.Parallel()
+
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("create", "selfsubjectaccessreviews",
+		func(action clienttesting.Action) (bool, runtime.Object, error) {
+			review := action.(clienttesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+			attributes := review.Spec.ResourceAttributes
+
+			switch {
+			case attributes.Resource == "events":
+				return true, nil, errors.New("the API server is unavailable")
+			case attributes.Subresource == "status":
+				review.Status.EvaluationError = "no RBAC policy matched"
+			default:
+				review.Status.Allowed = attributes.Verb == "get" || attributes.Verb == "list"
+				review.Status.Reason = "allowed by the policy-reader role"
+			}
+
+			return true, review, nil
+		})
+
+	results := CheckPermissions(
+		context.TODO(), clientset.AuthorizationV1().SelfSubjectAccessReviews(), HubPermissions("cluster"),
+	)
+
+	if len(results) != 4 {
+		t.Fatalf("expected a result for each hub permission, got %d", len(results))
+	}
+
+	expected := []struct {
+		permission string
+		allowed    bool
+		reason     string
+	}{
+		{"get policies.policy.open-cluster-management.io in namespace cluster", true, "policy-reader"},
+		{"list policies.policy.open-cluster-management.io in namespace cluster", true, "policy-reader"},
+		{
+			"update policies/status.policy.open-cluster-management.io in namespace cluster", false,
+			"no RBAC policy matched",
+		},
+		{"create events in namespace cluster", false, "the access review failed: the API server is unavailable"},
+	}
+
+	for i, want := range expected {
+		if results[i].Permission.String() != want.permission || results[i].Allowed != want.allowed ||
+			!strings.Contains(results[i].Reason, want.reason) {
+			t.Fatalf("expected %+v, got %+v", want, results[i])
+		}
+	}
+
+	out := &bytes.Buffer{}
+
+	passed, err := WritePermissionReport(out, "hub", results)
+	if err != nil {
+		t.Fatalf("failed to write the report: %v", err)
+	}
+
+	if passed {
+		t.Fatal("expected the report to fail")
+	}
+
+	report := out.String()
+	if !strings.HasPrefix(report, "Permissions on the hub cluster:\n") || strings.Count(report, "[PASS]") != 2 ||
+		strings.Count(report, "[FAIL]") != 2 {
+		t.Fatalf("unexpected report:\n%s", report)
+	}
+
+	passed, err = WritePermissionReport(out, "managed", results[:2])
+	if err != nil || !passed {
+		t.Fatalf("expected the report of allowed permissions to pass, got %v", err)
+	}
+}
+
+func TestManagedPermissions(t *testing.T) {
+	t.Parallel()
+
+	permissions := map[string]bool{}
+	for _, permission := range append(ManagedPermissions("managed"), LeasePermissions("agent")...) {
+		permissions[permission.String()] = true
+	}
+
+	// the permissions of the kubebuilder RBAC markers that the reconcile and the addon lease depend on
+	for _, required := range []string{
+		"watch policies.policy.open-cluster-management.io in namespace managed",
+		"update policies/status.policy.open-cluster-management.io in namespace managed",
+		"list events in namespace managed",
+		"get configmaps in namespace managed",
+		"create configmaps in namespace managed",
+		"update configmaps in namespace managed",
+		"get pods in namespace agent",
+		"list pods in namespace agent",
+	} {
+		if !permissions[required] {
+			t.Fatalf("expected the %q permission", required)
+		}
+	}
+}
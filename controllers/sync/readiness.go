@@ -0,0 +1,97 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+)
+
+var errCacheNotSynced = errors.New("the managed cluster cache is not synced")
+
+// ReadyzChecks returns the readiness checks of the controller keyed by their name. Each check must complete within
+// the input timeout. The result of every check is listed on the /readyz?verbose endpoint and the reason of a failed
+// check is returned by the /readyz/<name> endpoint.
+func (r *PolicyReconciler) ReadyzChecks(managedCache cache.Cache, timeout time.Duration) map[string]healthz.Checker {
+	return map[string]healthz.Checker{
+		"managed-cache-sync": func(req *http.Request) error {
+			ctx, cancel := context.WithTimeout(req.Context(), timeout)
+			defer cancel()
+
+			if !managedCache.WaitForCacheSync(ctx) {
+				return errCacheNotSynced
+			}
+
+			return nil
+		},
+		"hub-api": func(req *http.Request) error {
+			ctx, cancel := context.WithTimeout(req.Context(), timeout)
+			defer cancel()
+
+			return r.checkHubReachable(ctx)
+		},
+		"hub-policies": func(req *http.Request) error {
+			ctx, cancel := context.WithTimeout(req.Context(), timeout)
+			defer cancel()
+
+			return r.checkHubPolicies(ctx)
+		},
+	}
+}
+
+// checkHubReachable verifies that the hub API server responds. Any response from the API server, including a
+// forbidden or not found error, means that the hub is reachable. It passes without a hub client.
+func (r *PolicyReconciler) checkHubReachable(ctx context.Context) error {
+	r.hubLock.RLock()
+	defer r.hubLock.RUnlock()
+
+	if r.sinkOnly() {
+		return nil
+	}
+
+	err := r.HubClient.Get(ctx, types.NamespacedName{Name: r.ClusterNamespaceOnHub}, &corev1.Namespace{})
+
+	var statusErr k8serrors.APIStatus
+	if err != nil && !errors.As(err, &statusErr) {
+		log.Error(err, "Readiness check failed, the hub API server is not reachable")
+
+		return fmt.Errorf("the hub API server is not reachable: %w", err)
+	}
+
+	return nil
+}
+
+// checkHubPolicies verifies that the policies in the cluster namespace on the hub can be read. It passes without a
+// hub client.
+func (r *PolicyReconciler) checkHubPolicies(ctx context.Context) error {
+	r.hubLock.RLock()
+	defer r.hubLock.RUnlock()
+
+	if r.sinkOnly() {
+		return nil
+	}
+
+	err := r.HubClient.List(
+		ctx, &policiesv1.PolicyList{}, client.InNamespace(r.ClusterNamespaceOnHub), client.Limit(1),
+	)
+	if err != nil {
+		log.Error(err, "Readiness check failed, unable to list the policies on the hub",
+			"HubNamespace", r.ClusterNamespaceOnHub)
+
+		return fmt.Errorf("unable to list the policies in the namespace %s on the hub: %w",
+			r.ClusterNamespaceOnHub, err)
+	}
+
+	return nil
+}
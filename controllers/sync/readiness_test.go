@@ -0,0 +1,108 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// failingClient is a client whose reads fail with the input error.
+type failingClient struct {
+	client.Client
+	err error
+}
+
+func (c failingClient) Get(_ context.Context, _ client.ObjectKey, _ client.Object) error {
+	return c.err
+}
+
+func (c failingClient) List(_ context.Context, _ client.ObjectList, _ ...client.ListOption) error {
+	return c.err
+}
+
+func TestReadyzChecks(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build the scheme: %v", err)
+	}
+
+	if err := policiesv1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build the scheme: %v", err)
+	}
+
+	forbidden := k8serrors.NewForbidden(schema.GroupResource{Resource: "policies"}, "", errors.New("denied"))
+	synced, notSynced := true, false
+
+	tests := map[string]struct {
+		hubClient client.Client
+		synced    *bool
+		failed    map[string]string
+	}{
+		"ready": {
+			hubClient: fake.NewClientBuilder().WithScheme(scheme).Build(),
+			synced:    &synced,
+		},
+		"cache not synced": {
+			hubClient: fake.NewClientBuilder().WithScheme(scheme).Build(),
+			synced:    &notSynced,
+			failed:    map[string]string{"managed-cache-sync": "the managed cluster cache is not synced"},
+		},
+		"hub unreachable": {
+			hubClient: failingClient{err: errors.New("connection refused")},
+			synced:    &synced,
+			failed: map[string]string{
+				"hub-api":      "the hub API server is not reachable: connection refused",
+				"hub-policies": "unable to list the policies in the namespace cluster on the hub",
+			},
+		},
+		"policies forbidden on the hub": {
+			hubClient: failingClient{err: forbidden},
+			synced:    &synced,
+			failed:    map[string]string{"hub-policies": "unable to list the policies in the namespace cluster"},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r := &PolicyReconciler{HubClient: test.hubClient, ClusterNamespaceOnHub: "cluster"}
+			checks := r.ReadyzChecks(&informertest.FakeInformers{Synced: test.synced}, time.Second)
+
+			if len(checks) != 3 {
+				t.Fatalf("expected 3 checks, got %d", len(checks))
+			}
+
+			for check, checker := range checks {
+				err := checker(httptest.NewRequest("GET", "/readyz", nil))
+
+				want, fails := test.failed[check]
+				if !fails && err != nil {
+					t.Fatalf("expected the %s check to pass, got %v", check, err)
+				}
+
+				if fails && (err == nil || !strings.Contains(err.Error(), want)) {
+					t.Fatalf("expected the %s check to fail with %q, got %v", check, want, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/ratelimiter"
+)
+
+// Defaults of the reconciler settings, which are also the defaults of the command line flags
+const (
+	// DefaultHistoryLimit is the default number of history entries kept per policy template
+	DefaultHistoryLimit = 10
+	// DefaultMaxConcurrentReconciles is the default number of policies reconciled in parallel
+	DefaultMaxConcurrentReconciles = 1
+	// DefaultRecoveryFightThreshold is the default number of recoveries after which the controller backs off from
+	// recovering a managed policy. Zero disables the detection.
+	DefaultRecoveryFightThreshold = 0
+	// DefaultRecoveryFightWindow is the default window in which the recoveries of a managed policy are counted
+	DefaultRecoveryFightWindow = time.Minute
+	// DefaultRecoveryBackoffMax is the default maximum delay of the backoff from recovering a managed policy
+	DefaultRecoveryBackoffMax = 30 * time.Minute
+	// DefaultFlapWindow is the default window in which the compliance state changes of a policy template are counted
+	DefaultFlapWindow = 10 * time.Minute
+)
+
+// defaultFieldManager returns the field manager that the API server records for a client without an explicit field
+// manager, which is the beginning of its default user agent.
+func defaultFieldManager() string {
+	return strings.Split(rest.DefaultKubernetesUserAgent(), "/")[0]
+}
+
+// Option configures the PolicyReconciler built by NewPolicyReconciler.
+type Option func(*PolicyReconciler)
+
+// NewPolicyReconciler returns a PolicyReconciler configured with the input options. The hub and managed clients and
+// recorders, the scheme and the cluster namespace on the hub are required; the other settings default to the
+// defaults of the command line flags. An error is returned if a setting is missing or invalid.
+func NewPolicyReconciler(options ...Option) (*PolicyReconciler, error) {
+	r := &PolicyReconciler{
+		MaxConcurrentReconciles: DefaultMaxConcurrentReconciles,
+		HistoryLimit:            DefaultHistoryLimit,
+		RecoveryFightThreshold:  DefaultRecoveryFightThreshold,
+		RecoveryFightWindow:     DefaultRecoveryFightWindow,
+		RecoveryBackoffMax:      DefaultRecoveryBackoffMax,
+		FlapWindow:              DefaultFlapWindow,
+		StatusSizeBudget:        DefaultStatusSizeBudget,
+		FieldManager:            defaultFieldManager(),
+	}
+
+	for _, option := range options {
+		option(r)
+	}
+
+	// the API calls are recorded as child spans of the reconcile span when tracing is enabled
+	r.HubClient = traceClient(r.HubClient, hubCluster)
+	r.ManagedClient = traceClient(r.ManagedClient, managedCluster)
+
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// validate returns an error listing the missing or invalid settings of the reconciler.
+func (r *PolicyReconciler) validate() error {
+	errs := []error{}
+
+	if (r.HubClient == nil || r.HubRecorder == nil) && r.StatusSink == nil {
+		errs = append(errs, errors.New("the hub client and recorder are required without a status sink"))
+	}
+
+	if r.sinkOnly() && !r.StatusOnly {
+		errs = append(errs, errors.New("the status-only mode is required without a hub client"))
+	}
+
+	if r.ManagedClient == nil || r.ManagedRecorder == nil {
+		errs = append(errs, errors.New("the managed client and recorder are required"))
+	}
+
+	if r.Scheme == nil {
+		errs = append(errs, errors.New("the scheme is required"))
+	}
+
+	if r.ClusterNamespaceOnHub == "" {
+		errs = append(errs, errors.New("the cluster namespace on the hub is required"))
+	}
+
+	if r.MaxConcurrentReconciles < 1 {
+		errs = append(errs, fmt.Errorf(
+			"the maximum concurrent reconciles must be at least 1, got %d", r.MaxConcurrentReconciles,
+		))
+	}
+
+	if r.HistoryLimit < 1 {
+		errs = append(errs, fmt.Errorf("the history limit must be at least 1, got %d", r.HistoryLimit))
+	}
+
+	if r.MassDeletionThreshold < 0 || r.MassDeletionThreshold > 1 {
+		errs = append(errs, fmt.Errorf(
+			"the mass deletion threshold must be between 0 and 1, got %v", r.MassDeletionThreshold,
+		))
+	}
+
+	if r.RecoveryFightThreshold > 0 && (r.RecoveryFightWindow <= 0 || r.RecoveryBackoffMax <= 0) {
+		errs = append(errs, errors.New("the recovery fight window and maximum backoff must be positive"))
+	}
+
+	for _, setting := range []struct {
+		name     string
+		duration time.Duration
+	}{
+		{"resync period", r.ResyncPeriod},
+		{"deletion grace period", r.DeletionGracePeriod},
+		{"stale threshold", r.StaleThreshold},
+		{"flap window", r.FlapWindow},
+		{"flap damping period", r.FlapDampingPeriod},
+	} {
+		if setting.duration < 0 {
+			errs = append(errs, fmt.Errorf("the %s must not be negative, got %s", setting.name, setting.duration))
+		}
+	}
+
+	for kind, threshold := range r.StaleThresholdPerKind {
+		if threshold < 0 {
+			errs = append(errs, fmt.Errorf("the stale threshold of the %s kind must not be negative", kind))
+		}
+	}
+
+	if r.StatusSizeBudget < 0 {
+		errs = append(errs, fmt.Errorf("the status size budget must not be negative, got %d", r.StatusSizeBudget))
+	}
+
+	if r.MessageRedaction.MaxLength < 0 ||
+		(r.MessageRedaction.MaxLength > 0 && r.MessageRedaction.MaxLength < MinMessageMaxLength) {
+		errs = append(errs, fmt.Errorf(
+			"the maximum message length must be 0 or at least %d to keep the compliance state, got %d",
+			MinMessageMaxLength, r.MessageRedaction.MaxLength,
+		))
+	}
+
+	if r.FlapThreshold < 0 || r.RecoveryFightThreshold < 0 {
+		errs = append(errs, errors.New("the flap and recovery fight thresholds must not be negative"))
+	}
+
+	// the changes are counted between consecutive history entries, so the kept history limits how many can be seen
+	if r.HistoryLimit >= 1 && r.FlapThreshold > r.HistoryLimit-1 {
+		errs = append(errs, fmt.Errorf(
+			"the flap threshold %d can never be reached with the history limit %d, which keeps at most %d compliance "+
+				"state changes per policy template", r.FlapThreshold, r.HistoryLimit, r.HistoryLimit-1,
+		))
+	}
+
+	return errors.Join(errs...)
+}
+
+// WithHub sets the client and the event recorder of the hub cluster.
+func WithHub(hubClient client.Client, hubRecorder record.EventRecorder) Option {
+	return func(r *PolicyReconciler) {
+		r.HubClient = hubClient
+		r.HubRecorder = hubRecorder
+	}
+}
+
+// WithManaged sets the client and the event recorder of the managed cluster, and the scheme.
+func WithManaged(managedClient client.Client, managedRecorder record.EventRecorder, scheme *runtime.Scheme) Option {
+	return func(r *PolicyReconciler) {
+		r.ManagedClient = managedClient
+		r.ManagedRecorder = managedRecorder
+		r.Scheme = scheme
+	}
+}
+
+// WithManager sets the client, the event recorder and the scheme of the managed cluster from the input manager. The
+// conditions ConfigMaps are read with its API reader, so that all the ConfigMaps of the cluster aren't cached.
+func WithManager(mgr manager.Manager) Option {
+	withManaged := WithManaged(mgr.GetClient(), mgr.GetEventRecorderFor(ControllerName), mgr.GetScheme())
+
+	return func(r *PolicyReconciler) {
+		withManaged(r)
+		r.ManagedReader = mgr.GetAPIReader()
+	}
+}
+
+// WithClusterNamespaceOnHub sets the namespace of the managed cluster on the hub.
+func WithClusterNamespaceOnHub(namespace string) Option {
+	return func(r *PolicyReconciler) {
+		r.ClusterNamespaceOnHub = namespace
+	}
+}
+
+// WithConcurrency sets the number of policies reconciled in parallel and the rate limiter of the requeued requests.
+// A nil rate limiter keeps the controller-runtime default.
+func WithConcurrency(maxConcurrentReconciles int, rateLimiter ratelimiter.RateLimiter) Option {
+	return func(r *PolicyReconciler) {
+		r.MaxConcurrentReconciles = maxConcurrentReconciles
+		r.RateLimiter = rateLimiter
+	}
+}
+
+// WithResyncPeriod sets the interval at which every policy is reconciled again.
+func WithResyncPeriod(period time.Duration) Option {
+	return func(r *PolicyReconciler) {
+		r.ResyncPeriod = period
+	}
+}
+
+// WithHistoryLimit sets the number of history entries kept per policy template.
+func WithHistoryLimit(limit int) Option {
+	return func(r *PolicyReconciler) {
+		r.HistoryLimit = limit
+	}
+}
+
+// WithStatusOnly enables or disables the status-only mode.
+func WithStatusOnly(statusOnly bool) Option {
+	return func(r *PolicyReconciler) {
+		r.StatusOnly = statusOnly
+	}
+}
+
+// WithMetadataRules sets which labels and annotations are synced from the hub policy.
+func WithMetadataRules(rules MetadataSyncRules) Option {
+	return func(r *PolicyReconciler) {
+		r.MetadataRules = rules
+	}
+}
+
+// WithDeletionSafeguards sets the deletion grace period and the mass deletion threshold.
+func WithDeletionSafeguards(gracePeriod time.Duration, massDeletionThreshold float64) Option {
+	return func(r *PolicyReconciler) {
+		r.DeletionGracePeriod = gracePeriod
+		r.MassDeletionThreshold = massDeletionThreshold
+	}
+}
+
+// WithRecoveryBackoff sets the detection of the actors fighting the recovery of the managed policies.
+func WithRecoveryBackoff(threshold int, window time.Duration, maxBackoff time.Duration) Option {
+	return func(r *PolicyReconciler) {
+		r.RecoveryFightThreshold = threshold
+		r.RecoveryFightWindow = window
+		r.RecoveryBackoffMax = maxBackoff
+	}
+}
+
+// WithStaleThresholds sets the default staleness threshold and the thresholds per policy template kind.
+func WithStaleThresholds(threshold time.Duration, perKind map[string]time.Duration) Option {
+	return func(r *PolicyReconciler) {
+		r.StaleThreshold = threshold
+		r.StaleThresholdPerKind = perKind
+	}
+}
+
+// WithFlapDetection sets the detection of flapping policy templates and the damping of their hub status writes.
+func WithFlapDetection(threshold int, window time.Duration, dampingPeriod time.Duration) Option {
+	return func(r *PolicyReconciler) {
+		r.FlapThreshold = threshold
+		r.FlapWindow = window
+		r.FlapDampingPeriod = dampingPeriod
+	}
+}
+
+// WithPolicyReports enables or disables the export of the policy statuses as PolicyReports.
+func WithPolicyReports(export bool) Option {
+	return func(r *PolicyReconciler) {
+		r.ExportPolicyReports = export
+	}
+}
+
+// WithStatusSink replaces the default HubStatusSink.
+func WithStatusSink(sink StatusSink) Option {
+	return func(r *PolicyReconciler) {
+		r.StatusSink = sink
+	}
+}
+
+// WithNotificationSinks adds sinks notified of the compliance state changes.
+func WithNotificationSinks(sinks ...NotificationSink) Option {
+	return func(r *PolicyReconciler) {
+		r.NotificationSinks = append(r.NotificationSinks, sinks...)
+	}
+}
+
+// WithAuditLog sets the audit log of the changes made by the controller.
+func WithAuditLog(auditLog *AuditLog) Option {
+	return func(r *PolicyReconciler) {
+		r.AuditLog = auditLog
+	}
+}
+
+// WithMessageRedaction sets the redaction of the history messages before they leave the managed cluster.
+func WithMessageRedaction(redaction MessageRedaction) Option {
+	return func(r *PolicyReconciler) {
+		r.MessageRedaction = redaction
+	}
+}
+
+// WithStatusSizeBudget sets the maximum size in bytes of a policy with its status.
+func WithStatusSizeBudget(budget int) Option {
+	return func(r *PolicyReconciler) {
+		r.StatusSizeBudget = budget
+	}
+}
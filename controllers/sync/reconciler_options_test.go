@@ -0,0 +1,73 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestNewPolicyReconciler(t *testing.T) {
+	t.Parallel()
+
+	required := []Option{
+		WithHub(fake.NewClientBuilder().Build(), record.NewFakeRecorder(1)),
+		WithManaged(fake.NewClientBuilder().Build(), record.NewFakeRecorder(1), runtime.NewScheme()),
+		WithClusterNamespaceOnHub("cluster"),
+	}
+
+	r, err := NewPolicyReconciler(append(required, WithHistoryLimit(20), WithStatusOnly(true))...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if r.HistoryLimit != 20 || !r.StatusOnly || r.MaxConcurrentReconciles != 1 || r.FlapWindow != 10*time.Minute {
+		t.Fatalf("unexpected settings: %+v", r)
+	}
+
+	tests := map[string]struct {
+		options []Option
+		want    string
+	}{
+		"missing hub":         {options: required[1:], want: "the hub client and recorder are required"},
+		"missing namespace":   {options: required[:2], want: "the cluster namespace on the hub is required"},
+		"invalid concurrency": {options: []Option{WithConcurrency(0, nil)}, want: "at least 1, got 0"},
+		"invalid threshold": {
+			options: []Option{WithDeletionSafeguards(0, 1.5)}, want: "between 0 and 1, got 1.5",
+		},
+		"unreachable flap threshold": {
+			options: []Option{WithHistoryLimit(5), WithFlapDetection(5, time.Minute, 0)},
+			want:    "the flap threshold 5 can never be reached with the history limit 5",
+		},
+		"message length without the compliance state": {
+			options: []Option{WithMessageRedaction(MessageRedaction{MaxLength: MinMessageMaxLength - 1})},
+			want:    "the maximum message length must be 0 or at least 16",
+		},
+		"negative duration": {
+			options: []Option{WithStaleThresholds(-time.Minute, nil)}, want: "stale threshold must not be negative",
+		},
+	}
+
+	for name, test := range tests {
+		name, test := name, test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			options := test.options
+			if !strings.HasPrefix(name, "missing") {
+				options = append(append([]Option{}, required...), test.options...)
+			}
+
+			_, err := NewPolicyReconciler(options...)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("expected an error containing %q, got %v", test.want, err)
+			}
+		})
+	}
+}
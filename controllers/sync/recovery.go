@@ -0,0 +1,206 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"fmt"
+	gosync "sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+)
+
+// Kinds of spec recovery of the managed policy
+const (
+	recoveryRecreate = "recreate"
+	recoveryRevert   = "revert"
+)
+
+// unknownActor is reported when the actor which changed the managed policy can't be determined
+const unknownActor = "unknown"
+
+// recoveryState is the recent spec recovery activity of a policy.
+type recoveryState struct {
+	// recoveries are the times of the recoveries within the current window
+	recoveries []time.Time
+	// backoffs is the number of consecutive backoffs, which determines the next backoff delay
+	backoffs     int
+	backoffUntil time.Time
+	// hubVersion identifies the spec, labels and annotations of the hub policy when it was last observed
+	hubVersion string
+}
+
+// recoveryTracker tracks how often the managed policies are recovered from the hub, to detect another actor
+// repeatedly deleting or editing a managed policy.
+type recoveryTracker struct {
+	lock     gosync.Mutex
+	policies map[types.NamespacedName]*recoveryState
+}
+
+// backoffRemaining returns how long the recovery of the input policy must still be held, or zero if it is allowed.
+func (t *recoveryTracker) backoffRemaining(key types.NamespacedName) time.Duration {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	state, found := t.policies[key]
+	if !found {
+		return 0
+	}
+
+	remaining := time.Until(state.backoffUntil)
+	if remaining < 0 {
+		return 0
+	}
+
+	return remaining
+}
+
+// record records a recovery of the input policy. When the threshold of recoveries within the window is reached, a
+// backoff is started and its delay is returned. The delay doubles on consecutive backoffs, up to maxBackoff.
+func (t *recoveryTracker) record(
+	key types.NamespacedName, threshold int, window time.Duration, maxBackoff time.Duration,
+) (int, time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.policies == nil {
+		t.policies = map[types.NamespacedName]*recoveryState{}
+	}
+
+	state, found := t.policies[key]
+	if !found {
+		state = &recoveryState{}
+		t.policies[key] = state
+	}
+
+	now := time.Now()
+	recoveries := []time.Time{}
+
+	for _, recovery := range state.recoveries {
+		if now.Sub(recovery) < window {
+			recoveries = append(recoveries, recovery)
+		}
+	}
+
+	// the fight stopped for a whole window after the last backoff, so start over
+	if len(recoveries) == 0 && now.Sub(state.backoffUntil) > window {
+		state.backoffs = 0
+	}
+
+	state.recoveries = append(recoveries, now)
+
+	count := len(state.recoveries)
+	if count < threshold {
+		return count, 0
+	}
+
+	backoff := window << state.backoffs
+	if backoff > maxBackoff || backoff <= 0 {
+		backoff = maxBackoff
+	} else {
+		state.backoffs++
+	}
+
+	state.backoffUntil = now.Add(backoff)
+	state.recoveries = nil
+
+	return count, backoff
+}
+
+// observeHub records the spec, labels and annotations of the hub policy and returns whether they changed since the
+// last observation, in which case a mismatch with the managed policy is caused by an update of the hub policy rather
+// than a change on the managed cluster. A policy observed for the first time is reported as changed.
+func (t *recoveryTracker) observeHub(key types.NamespacedName, hubPlc *policiesv1.Policy) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.policies == nil {
+		t.policies = map[types.NamespacedName]*recoveryState{}
+	}
+
+	state, found := t.policies[key]
+	if !found {
+		state = &recoveryState{}
+		t.policies[key] = state
+	}
+
+	// the generation only changes with the spec, and fmt prints the maps sorted by key
+	hubVersion := fmt.Sprintf("%d %v %v", hubPlc.GetGeneration(), hubPlc.GetLabels(), hubPlc.GetAnnotations())
+	changed := state.hubVersion != hubVersion
+	state.hubVersion = hubVersion
+
+	return changed
+}
+
+// forget stops tracking the input policy and deletes its recovery metrics, because it was deleted.
+func (t *recoveryTracker) forget(key types.NamespacedName) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	delete(t.policies, key)
+
+	for _, kind := range []string{recoveryRecreate, recoveryRevert} {
+		recoveriesCounter.DeleteLabelValues(key.Namespace, key.Name, kind)
+	}
+
+	recoveryFightsCounter.DeleteLabelValues(key.Namespace, key.Name)
+}
+
+// lastSpecWriter returns the field manager, other than the input field manager of this controller, that most
+// recently wrote to the managed policy outside of the status subresource, based on its managed fields.
+func lastSpecWriter(plc *policiesv1.Policy, ownManager string) string {
+	actor := unknownActor
+
+	var lastTime time.Time
+
+	for _, entry := range plc.GetManagedFields() {
+		if entry.Subresource != "" || entry.Time == nil || entry.Manager == ownManager {
+			continue
+		}
+
+		if entry.Time.Time.After(lastTime) || entry.Time.Time.Equal(lastTime) {
+			lastTime = entry.Time.Time
+			actor = fmt.Sprintf("%s (%s at %s)", entry.Manager, entry.Operation, entry.Time.UTC().Format(time.RFC3339))
+		}
+	}
+
+	return actor
+}
+
+// recordRecovery records a recovery of the managed policy and, when it is fighting with another actor, reports it
+// through a warning event and a metric. Only the recoveries of a change made on the managed cluster, as indicated
+// by managedEdit, are counted towards a fight, so that the updates of the hub policy don't trigger a backoff. It
+// returns the delay before the policy may be recovered again.
+func (r *PolicyReconciler) recordRecovery(
+	plc *policiesv1.Policy, kind string, actor string, managedEdit bool,
+) time.Duration {
+	key := types.NamespacedName{Namespace: plc.GetNamespace(), Name: plc.GetName()}
+
+	recoveriesCounter.WithLabelValues(plc.GetNamespace(), plc.GetName(), kind).Inc()
+
+	if r.RecoveryFightThreshold <= 0 || !managedEdit {
+		return 0
+	}
+
+	count, backoff := r.recoveries.record(
+		key, r.RecoveryFightThreshold, r.RecoveryFightWindow, r.RecoveryBackoffMax,
+	)
+	if backoff == 0 {
+		return 0
+	}
+
+	log.Info("The managed policy is repeatedly changed by another actor, backing off from recovering it",
+		"Request.Namespace", plc.GetNamespace(), "Request.Name", plc.GetName(), "recoveries", count,
+		"window", r.RecoveryFightWindow.String(), "actor", actor, "backoff", backoff.String())
+
+	recoveryFightsCounter.WithLabelValues(plc.GetNamespace(), plc.GetName()).Inc()
+
+	r.ManagedRecorder.Event(plc, "Warning", "PolicyRecoveryBackoff", fmt.Sprintf(
+		"The policy was recovered from the hub %d times within %s and was last changed by %s. "+
+			"Another actor may be fighting with the policy status sync, the next recovery is held for %s.",
+		count, r.RecoveryFightWindow, actor, backoff,
+	))
+
+	return backoff
+}
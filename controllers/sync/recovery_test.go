@@ -0,0 +1,266 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+)
+
+func TestRecoveryTrackerRecord(t *testing.T) {
+	t.Parallel()
+
+	tracker := recoveryTracker{}
+	key := types.NamespacedName{Namespace: "managed", Name: "policy"}
+
+	// the backoff starts at the window, doubles on consecutive backoffs and is capped at the maximum backoff
+	for _, expected := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute} {
+		for i := 1; i < 3; i++ {
+			if count, backoff := tracker.record(key, 3, time.Minute, 5*time.Minute); count != i || backoff != 0 {
+				t.Fatalf("expected %d recoveries without a backoff, got %d and %s", i, count, backoff)
+			}
+		}
+
+		if count, backoff := tracker.record(key, 3, time.Minute, 5*time.Minute); count != 3 || backoff != expected {
+			t.Fatalf("expected a backoff of %s after 3 recoveries, got %d and %s", expected, count, backoff)
+		}
+
+		if remaining := tracker.backoffRemaining(key); remaining <= expected-time.Second || remaining > expected {
+			t.Fatalf("expected a remaining backoff of %s, got %s", expected, remaining)
+		}
+	}
+
+	other := types.NamespacedName{Namespace: "managed", Name: "other"}
+	if remaining := tracker.backoffRemaining(other); remaining != 0 {
+		t.Fatalf("expected no backoff of another policy, got %s", remaining)
+	}
+
+	// the recoveries outside of the window and the backoffs of a fight that stopped are no longer counted
+	tracker.policies[key].recoveries = []time.Time{time.Now().Add(-2 * time.Minute)}
+	tracker.policies[key].backoffUntil = time.Now().Add(-2 * time.Minute)
+
+	if count, backoff := tracker.record(key, 2, time.Minute, 5*time.Minute); count != 1 || backoff != 0 {
+		t.Fatalf("expected the old recovery to not be counted, got %d and %s", count, backoff)
+	}
+
+	if count, backoff := tracker.record(key, 2, time.Minute, 5*time.Minute); count != 2 || backoff != time.Minute {
+		t.Fatalf("expected the backoff to start over, got %d and %s", count, backoff)
+	}
+
+	tracker.forget(key)
+
+	if remaining := tracker.backoffRemaining(key); remaining != 0 {
+		t.Fatalf("expected no backoff after forgetting the policy, got %s", remaining)
+	}
+}
+
+func TestRecoveryTrackerObserveHub(t *testing.T) {
+	t.Parallel()
+
+	tracker := recoveryTracker{}
+	key := types.NamespacedName{Namespace: "managed", Name: "policy"}
+	hubPlc := &policiesv1.Policy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "policy", Namespace: "cluster", Generation: 1, Annotations: map[string]string{"a": "1", "b": "2"},
+		},
+	}
+
+	if !tracker.observeHub(key, hubPlc) {
+		t.Fatal("expected the first observation to be reported as a change")
+	}
+
+	// a status update doesn't change the generation
+	hubPlc.SetResourceVersion("2")
+	hubPlc.Status.ComplianceState = policiesv1.Compliant
+
+	if tracker.observeHub(key, hubPlc) {
+		t.Fatal("expected a status update to not be reported as a change")
+	}
+
+	hubPlc.SetAnnotations(map[string]string{"b": "2", "a": "1"})
+
+	if tracker.observeHub(key, hubPlc) {
+		t.Fatal("expected the same annotations to not be reported as a change")
+	}
+
+	hubPlc.SetLabels(map[string]string{"team": "governance"})
+
+	if !tracker.observeHub(key, hubPlc) {
+		t.Fatal("expected a label update to be reported as a change")
+	}
+
+	hubPlc.SetGeneration(2)
+
+	if !tracker.observeHub(key, hubPlc) {
+		t.Fatal("expected a spec update to be reported as a change")
+	}
+}
+
+func TestLastSpecWriter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	entry := func(manager string, ago time.Duration, subresource string) metav1.ManagedFieldsEntry {
+		return metav1.ManagedFieldsEntry{
+			Manager:     manager,
+			Operation:   metav1.ManagedFieldsOperationUpdate,
+			Time:        &metav1.Time{Time: now.Add(-ago)},
+			Subresource: subresource,
+		}
+	}
+
+	tests := map[string]struct {
+		managedFields []metav1.ManagedFieldsEntry
+		expected      string
+	}{
+		"no managed fields": {expected: unknownActor},
+		"latest writer": {
+			managedFields: []metav1.ManagedFieldsEntry{
+				entry("kubectl-edit", time.Minute, ""),
+				entry("governance-policy-spec-sync", time.Hour, ""),
+			},
+			expected: "kubectl-edit (Update at 2023-05-01T11:59:00Z)",
+		},
+		"status writes ignored": {
+			managedFields: []metav1.ManagedFieldsEntry{
+				entry("kubectl-edit", time.Hour, ""),
+				entry("governance-policy-framework", time.Minute, "status"),
+			},
+			expected: "kubectl-edit (Update at 2023-05-01T11:00:00Z)",
+		},
+		"own writes ignored": {
+			managedFields: []metav1.ManagedFieldsEntry{
+				entry("kubectl-edit", time.Hour, ""),
+				entry("policy-status-sync", time.Minute, ""),
+			},
+			expected: "kubectl-edit (Update at 2023-05-01T11:00:00Z)",
+		},
+		"only own writes": {
+			managedFields: []metav1.ManagedFieldsEntry{entry("policy-status-sync", time.Minute, "")},
+			expected:      unknownActor,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			plc := &policiesv1.Policy{ObjectMeta: metav1.ObjectMeta{ManagedFields: test.managedFields}}
+
+			if actor := lastSpecWriter(plc, "policy-status-sync"); actor != test.expected {
+				t.Fatalf("expected the actor %q, got %q", test.expected, actor)
+			}
+		})
+	}
+}
+
+func TestRecordRecovery(t *testing.T) {
+	t.Parallel()
+
+	plc := &policiesv1.Policy{ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "recovery"}}
+
+	tests := map[string]struct {
+		threshold   int
+		managedEdit bool
+		backoff     bool
+	}{
+		"disabled by default":     {managedEdit: true},
+		"updates from the hub":    {threshold: 2},
+		"changes on the managed":  {threshold: 2, managedEdit: true, backoff: true},
+		"below the threshold":     {threshold: 4, managedEdit: true},
+		"single recovery allowed": {threshold: 1, managedEdit: true, backoff: true},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			recorder := record.NewFakeRecorder(10)
+			r := &PolicyReconciler{
+				ManagedRecorder:        recorder,
+				RecoveryFightThreshold: test.threshold,
+				RecoveryFightWindow:    time.Minute,
+				RecoveryBackoffMax:     time.Hour,
+			}
+
+			var backoff time.Duration
+
+			for i := 0; i < 2 && backoff == 0; i++ {
+				backoff = r.recordRecovery(plc, recoveryRevert, "kubectl-edit", test.managedEdit)
+			}
+
+			if test.backoff != (backoff > 0) || test.backoff != (len(recorder.Events) == 1) {
+				t.Fatalf("expected a backoff: %v, got %s and %d events", test.backoff, backoff, len(recorder.Events))
+			}
+		})
+	}
+}
+
+// policySeries returns the number of series of the input metric for the input policy.
+func policySeries(t *testing.T, collector prometheus.Collector, key types.NamespacedName) int {
+	t.Helper()
+
+	metrics := make(chan prometheus.Metric, 100)
+	collector.Collect(metrics)
+	close(metrics)
+
+	count := 0
+
+	for metric := range metrics {
+		written := &dto.Metric{}
+		if err := metric.Write(written); err != nil {
+			t.Fatalf("failed to read the metric: %v", err)
+		}
+
+		labels := map[string]string{}
+		for _, label := range written.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+
+		if labels["namespace"] == key.Namespace && labels["policy"] == key.Name {
+			count++
+		}
+	}
+
+	return count
+}
+
+func TestRecoveryTrackerForgetMetrics(t *testing.T) {
+	t.Parallel()
+
+	// a namespace of its own since the metrics are global
+	plc := &policiesv1.Policy{ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "recovery-metrics"}}
+	key := types.NamespacedName{Namespace: plc.Namespace, Name: plc.Name}
+	r := &PolicyReconciler{
+		ManagedRecorder:        record.NewFakeRecorder(10),
+		RecoveryFightThreshold: 1,
+		RecoveryFightWindow:    time.Minute,
+		RecoveryBackoffMax:     time.Hour,
+	}
+
+	r.recordRecovery(plc, recoveryRecreate, unknownActor, true)
+	r.recordRecovery(plc, recoveryRevert, "kubectl-edit", true)
+
+	recoveries, fights := policySeries(t, recoveriesCounter, key), policySeries(t, recoveryFightsCounter, key)
+	if recoveries != 2 || fights != 1 {
+		t.Fatalf("expected 2 recovery series and 1 fight series, got %d and %d", recoveries, fights)
+	}
+
+	r.recoveries.forget(key)
+
+	recoveries, fights = policySeries(t, recoveriesCounter, key), policySeries(t, recoveryFightsCounter, key)
+	if recoveries != 0 || fights != 0 {
+		t.Fatalf("expected the series of the deleted policy to be removed, got %d and %d", recoveries, fights)
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// truncationMarker ends the messages truncated to their maximum length
+const truncationMarker = "..."
+
+// MinMessageMaxLength is the minimum maximum length of the messages, which keeps the longest compliance state prefix,
+// "NonCompliant;", and the truncation marker, so the compliance state can still be read from a truncated message.
+const MinMessageMaxLength = len("NonCompliant;") + len(truncationMarker)
+
+// RedactionRule replaces the matches of Pattern in the history messages with Replacement, which can refer to the
+// submatches of the pattern, such as ${1}.
+type RedactionRule struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// MessageRedaction redacts the history messages before they leave the managed cluster, in the hub status and in the
+// compliance change notifications.
+type MessageRedaction struct {
+	// Rules are applied in order to each message, after its compliance state prefix such as "NonCompliant;"
+	Rules []RedactionRule
+	// MaxLength is the maximum number of characters of a message, including the truncation marker. It must be at
+	// least MinMessageMaxLength. Zero disables the truncation.
+	MaxLength int
+	// KeepFullOnManaged keeps the full messages in the status of the managed policy. Otherwise, the managed status is
+	// redacted too.
+	KeepFullOnManaged bool
+}
+
+// redactionRuleFile is an entry of a redaction rules file.
+type redactionRuleFile struct {
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement"`
+}
+
+// LoadRedactionRules reads the redaction rules from the input YAML file, a list of entries with a regular expression
+// pattern and its replacement.
+func LoadRedactionRules(path string) ([]RedactionRule, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []redactionRuleFile{}
+	if err := yaml.UnmarshalStrict(content, &entries); err != nil {
+		return nil, fmt.Errorf("invalid redaction rules file %s: %w", path, err)
+	}
+
+	rules := make([]RedactionRule, 0, len(entries))
+
+	for i, entry := range entries {
+		pattern, err := regexp.Compile(entry.Pattern)
+		if err != nil || entry.Pattern == "" {
+			return nil, fmt.Errorf("invalid pattern %q of the redaction rule %d: %v", entry.Pattern, i+1, err)
+		}
+
+		rules = append(rules, RedactionRule{Pattern: pattern, Replacement: entry.Replacement})
+	}
+
+	return rules, nil
+}
+
+// enabled returns whether the messages are redacted.
+func (m *MessageRedaction) enabled() bool {
+	return len(m.Rules) > 0 || m.MaxLength > 0
+}
+
+// Redact returns the input message with the redaction rules applied and truncated to the maximum length. The
+// compliance state prefix of the message is kept so the compliance state can still be read from it.
+func (m *MessageRedaction) Redact(message string) string {
+	if !m.enabled() {
+		return message
+	}
+
+	prefix, rest := "", message
+
+	if index := strings.Index(message, ";"); index > 0 {
+		state := strings.TrimSpace(message[:index])
+		if strings.EqualFold(state, string(policiesv1.Compliant)) ||
+			strings.EqualFold(state, string(policiesv1.NonCompliant)) || strings.EqualFold(state, "Pending") {
+			prefix, rest = message[:index+1], message[index+1:]
+		}
+	}
+
+	for _, rule := range m.Rules {
+		rest = rule.Pattern.ReplaceAllString(rest, rule.Replacement)
+	}
+
+	return truncateMessage(prefix+rest, m.MaxLength)
+}
+
+// truncateMessage truncates the input message to the input number of characters, ending it with the truncation
+// marker. A maximum length of zero disables the truncation.
+func truncateMessage(message string, maxLength int) string {
+	if maxLength <= 0 {
+		return message
+	}
+
+	characters := []rune(message)
+	if len(characters) <= maxLength {
+		return message
+	}
+
+	if maxLength <= len(truncationMarker) {
+		return string(characters[:maxLength])
+	}
+
+	return string(characters[:maxLength-len(truncationMarker)]) + truncationMarker
+}
+
+// redactStatus returns a copy of the input status with the history messages redacted, or the input status if the
+// messages aren't redacted. The history entries that are already in the input previous status, which was redacted
+// the same way, are kept as they are: the rules aren't necessarily idempotent, so a message is only redacted once.
+func (m *MessageRedaction) redactStatus(status, previous policiesv1.PolicyStatus) policiesv1.PolicyStatus {
+	if !m.enabled() {
+		return status
+	}
+
+	type historyKey struct {
+		template  string
+		eventName string
+		timestamp int64
+		message   string
+	}
+
+	redactedBefore := map[historyKey]bool{}
+
+	for _, dpt := range previous.Details {
+		if dpt == nil {
+			continue
+		}
+
+		for _, entry := range dpt.History {
+			redactedBefore[historyKey{
+				dpt.TemplateMeta.Name, entry.EventName, entry.LastTimestamp.Unix(), entry.Message,
+			}] = true
+		}
+	}
+
+	redacted := *status.DeepCopy()
+
+	for _, dpt := range redacted.Details {
+		if dpt == nil {
+			continue
+		}
+
+		for i, entry := range dpt.History {
+			if !redactedBefore[historyKey{
+				dpt.TemplateMeta.Name, entry.EventName, entry.LastTimestamp.Unix(), entry.Message,
+			}] {
+				dpt.History[i].Message = m.Redact(entry.Message)
+			}
+		}
+	}
+
+	return redacted
+}
+
+// managedStatus returns the input computed status as it is stored on the managed policy, which is redacted unless
+// the full messages are kept on the managed cluster. The input previous status of the managed policy is already
+// redacted, so only the new history entries are.
+func (m *MessageRedaction) managedStatus(status, previous policiesv1.PolicyStatus) policiesv1.PolicyStatus {
+	if m.KeepFullOnManaged {
+		return status
+	}
+
+	return m.redactStatus(status, previous)
+}
+
+// hubStatus returns the input managed status as it is written to the hub, which is redacted. The managed status is
+// already redacted unless the full messages are kept on the managed cluster.
+func (m *MessageRedaction) hubStatus(status policiesv1.PolicyStatus) policiesv1.PolicyStatus {
+	if !m.KeepFullOnManaged {
+		return status
+	}
+
+	return m.redactStatus(status, policiesv1.PolicyStatus{})
+}
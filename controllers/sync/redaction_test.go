@@ -0,0 +1,178 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+)
+
+func TestRedact(t *testing.T) {
+	t.Parallel()
+
+	rules := []RedactionRule{
+		{Pattern: regexp.MustCompile(`[a-z0-9-]+\.internal\.example\.com`), Replacement: "<host>"},
+		{Pattern: regexp.MustCompile(`(key )\S+`), Replacement: "${1}<redacted>"},
+		{Pattern: regexp.MustCompile(`(?i)noncompliant`), Replacement: "<state>"},
+	}
+
+	tests := map[string]struct {
+		redaction MessageRedaction
+		message   string
+		expected  string
+	}{
+		"disabled": {
+			redaction: MessageRedaction{},
+			message:   "NonCompliant; violation - db.internal.example.com",
+			expected:  "NonCompliant; violation - db.internal.example.com",
+		},
+		"replaced after the compliance state": {
+			redaction: MessageRedaction{Rules: rules},
+			message:   "NonCompliant; violation - key password missing on db.internal.example.com",
+			expected:  "NonCompliant; violation - key <redacted> missing on <host>",
+		},
+		"no compliance state": {
+			redaction: MessageRedaction{Rules: rules},
+			message:   "noncompliant object db.internal.example.com",
+			expected:  "<state> object <host>",
+		},
+		"truncated": {
+			redaction: MessageRedaction{MaxLength: 20},
+			message:   "Compliant; notification - the configmap is as expected",
+			expected:  "Compliant; notifi...",
+		},
+		"short enough": {
+			redaction: MessageRedaction{MaxLength: 20},
+			message:   "Compliant; ok",
+			expected:  "Compliant; ok",
+		},
+		"multibyte characters": {
+			redaction: MessageRedaction{MaxLength: 5},
+			message:   "éééééé",
+			expected:  "éé...",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if redacted := test.redaction.Redact(test.message); redacted != test.expected {
+				t.Fatalf("expected %q, got %q", test.expected, redacted)
+			}
+		})
+	}
+}
+
+func TestRedactStatus(t *testing.T) {
+	t.Parallel()
+
+	status := policiesv1.PolicyStatus{
+		Details: []*policiesv1.DetailsPerTemplate{{
+			History: []policiesv1.ComplianceHistory{{Message: "NonCompliant; violation - secret token missing"}},
+		}},
+	}
+
+	for _, keepFull := range []bool{false, true} {
+		redaction := MessageRedaction{
+			Rules:             []RedactionRule{{Pattern: regexp.MustCompile(`secret \S+`), Replacement: "secret ***"}},
+			KeepFullOnManaged: keepFull,
+		}
+
+		managed := redaction.managedStatus(status, policiesv1.PolicyStatus{})
+		hub := redaction.hubStatus(managed)
+
+		if hub.Details[0].History[0].Message != "NonCompliant; violation - secret *** missing" {
+			t.Fatalf("expected the hub message to be redacted, got %q", hub.Details[0].History[0].Message)
+		}
+
+		if keepFull != (managed.Details[0].History[0].Message == status.Details[0].History[0].Message) {
+			t.Fatalf("expected the managed message to be redacted unless kept full, got %q",
+				managed.Details[0].History[0].Message)
+		}
+	}
+
+	if status.Details[0].History[0].Message != "NonCompliant; violation - secret token missing" {
+		t.Fatal("expected the input status not to be modified")
+	}
+}
+
+func TestLoadRedactionRules(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+
+	err := os.WriteFile(path, []byte("- pattern: 'password=\\S+'\n  replacement: 'password=***'\n"), 0o600)
+	if err != nil {
+		t.Fatalf("failed to write the rules: %v", err)
+	}
+
+	rules, err := LoadRedactionRules(path)
+	if err != nil || len(rules) != 1 || rules[0].Replacement != "password=***" {
+		t.Fatalf("unexpected rules %v: %v", rules, err)
+	}
+
+	if err := os.WriteFile(path, []byte("- pattern: '('\n"), 0o600); err != nil {
+		t.Fatalf("failed to write the rules: %v", err)
+	}
+
+	if _, err := LoadRedactionRules(path); err == nil {
+		t.Fatal("expected an error for an invalid pattern")
+	}
+}
+
+func TestRedactStatusOnce(t *testing.T) {
+	t.Parallel()
+
+	// the rule isn't idempotent, so a message redacted twice would be different
+	redaction := MessageRedaction{
+		Rules:     []RedactionRule{{Pattern: regexp.MustCompile(`token`), Replacement: "token (redacted)"}},
+		MaxLength: 60,
+	}
+	timestamp := metav1.NewTime(time.Now().Add(-time.Minute))
+	computed := policiesv1.PolicyStatus{
+		Details: []*policiesv1.DetailsPerTemplate{{
+			TemplateMeta: metav1.ObjectMeta{Name: "template"},
+			History: []policiesv1.ComplianceHistory{
+				{EventName: "event.2", LastTimestamp: timestamp, Message: "NonCompliant; the token is missing"},
+			},
+		}},
+	}
+
+	managed := redaction.managedStatus(computed, policiesv1.PolicyStatus{})
+
+	expected := "NonCompliant; the token (redacted) is missing"
+	if message := managed.Details[0].History[0].Message; message != expected {
+		t.Fatalf("expected the message %q, got %q", expected, message)
+	}
+
+	// the next reconcile computes the status from the redacted managed status, with a new event
+	next := *managed.DeepCopy()
+	next.Details[0].History = append([]policiesv1.ComplianceHistory{{
+		EventName: "event.3", LastTimestamp: metav1.Now(), Message: "Compliant; the token is present",
+	}}, next.Details[0].History...)
+
+	redactedAgain := redaction.managedStatus(next, managed)
+
+	if message := redactedAgain.Details[0].History[1].Message; message != expected {
+		t.Fatalf("expected the message redacted before to be unchanged, got %q", message)
+	}
+
+	if message := redactedAgain.Details[0].History[0].Message; message != "Compliant; the token (redacted) is present" {
+		t.Fatalf("expected the new message to be redacted, got %q", message)
+	}
+
+	again := redaction.managedStatus(redactedAgain, redactedAgain)
+	if !equality.Semantic.DeepEqual(again, redactedAgain) {
+		t.Fatalf("expected redacting the same status twice to give the same result, got %+v", again)
+	}
+}
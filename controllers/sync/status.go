@@ -0,0 +1,304 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+)
+
+// ComplianceStateUnknown is the compliance state of a policy template whose newest event is older than its
+// staleness threshold, for example because its template controller stopped reporting.
+const ComplianceStateUnknown policiesv1.ComplianceState = "Unknown"
+
+// StaleThresholdAnnotation is the annotation on a policy that overrides the staleness threshold of its templates,
+// such as "1h". It is synced from the hub policy like the other annotations.
+const StaleThresholdAnnotation = "policy.open-cluster-management.io/stale-threshold"
+
+type historyEvent struct {
+	policiesv1.ComplianceHistory
+	eventTime metav1.MicroTime
+}
+
+// policyEvents filters the input events to the ones of the policy templates of the input policy and returns them
+// keyed by the policy template name. Events last emitted before the input time, such as when the policy was enabled
+// again, are ignored.
+func policyEvents(
+	instance *policiesv1.Policy, events []corev1.Event, since time.Time,
+) map[string]*[]historyEvent {
+	eventForPolicyMap := make(map[string]*[]historyEvent)
+	// panic if regexp invalid
+	rgx := regexp.MustCompile(`(?i)^policy:\s*([A-Za-z0-9.-]+)\s*\/([A-Za-z0-9.-]+)`)
+	for _, event := range events {
+		// sample event.Reason -- reason: 'policy: calamari/policy-grc-rbactest-example'
+		reason := rgx.FindString(event.Reason)
+		if event.InvolvedObject.Kind == policiesv1.Kind && event.InvolvedObject.APIVersion == policiesv1APIVersion &&
+			event.InvolvedObject.Name == instance.GetName() && reason != "" &&
+			!event.LastTimestamp.Time.Before(since) {
+			templateName := rgx.FindStringSubmatch(event.Reason)[2]
+			eventHistory := historyEvent{
+				ComplianceHistory: policiesv1.ComplianceHistory{
+					LastTimestamp: event.LastTimestamp,
+					Message: strings.TrimSpace(strings.TrimPrefix(
+						event.Message, "(combined from similar events):")),
+					EventName: event.GetName(),
+				},
+				eventTime: *event.EventTime.DeepCopy(),
+			}
+
+			if eventForPolicyMap[templateName] == nil {
+				eventForPolicyMap[templateName] = &[]historyEvent{}
+			}
+
+			templateEvents := append(*eventForPolicyMap[templateName], eventHistory)
+			eventForPolicyMap[templateName] = &templateEvents
+		}
+	}
+
+	return eventForPolicyMap
+}
+
+// eventCount returns the number of events in the input map of the events of each policy template.
+func eventCount(eventForPolicyMap map[string]*[]historyEvent) int {
+	count := 0
+
+	for _, events := range eventForPolicyMap {
+		count += len(*events)
+	}
+
+	return count
+}
+
+// computeStatus merges the events of each policy template with its existing history in the status of the input
+// policy and returns the resulting status. It also returns the delay after which a policy template becomes stale,
+// or zero if none does.
+func (r *PolicyReconciler) computeStatus(
+	instance *policiesv1.Policy, eventForPolicyMap map[string]*[]historyEvent, reqLogger logr.Logger,
+) (policiesv1.PolicyStatus, time.Duration) {
+	var staleRecheck time.Duration
+
+	newStatus := policiesv1.PolicyStatus{}
+
+	for _, policyT := range instance.Spec.PolicyTemplates {
+		object, _, err := unstructured.UnstructuredJSONScheme.Decode(policyT.ObjectDefinition.Raw, nil, nil)
+		if err != nil {
+			// failed to decode PolicyTemplate, skipping it
+			reqLogger.Error(err, "Failed to decode policy template, skipping it")
+
+			break
+		}
+
+		tName := object.(metav1.Object).GetName()
+		existingDpt := &policiesv1.DetailsPerTemplate{}
+		// retrieve existingDpt from instance.status.details field
+		found := false
+
+		for _, dpt := range instance.Status.Details {
+			if dpt.TemplateMeta.Name == tName {
+				// found existing status for policyTemplate
+				// retrieve it
+				existingDpt = dpt
+				found = true
+
+				reqLogger.Info("Found existing status, retrieving it", "PolicyTemplate", tName)
+
+				break
+			}
+		}
+		// no dpt from status field, initialize it
+		if !found {
+			existingDpt = &policiesv1.DetailsPerTemplate{
+				TemplateMeta: metav1.ObjectMeta{
+					Name: tName,
+				},
+				History: []policiesv1.ComplianceHistory{},
+			}
+		}
+
+		previousState := existingDpt.ComplianceState
+
+		history := []historyEvent{}
+		if eventForPolicyMap[tName] != nil {
+			history = *eventForPolicyMap[tName]
+		}
+
+		for _, ech := range existingDpt.History {
+			exists := false
+
+			for _, ch := range history {
+				if ch.LastTimestamp.Time.Equal(ech.LastTimestamp.Time) && ch.EventName == ech.EventName {
+					// do nothing
+					exists = true
+
+					break
+				}
+			}
+			// doesn't exist, append to history
+			if !exists {
+				history = append(history, historyEvent{ComplianceHistory: ech})
+			}
+		}
+		// sort by lasttimestamp, break ties with EventTime (if present) or EventName
+		sort.Slice(history, func(i, j int) bool {
+			if history[i].LastTimestamp.Equal(&history[j].LastTimestamp) {
+				if !history[i].eventTime.IsZero() && !history[j].eventTime.IsZero() {
+					reqLogger.V(2).Info("Event timestamp collision, order determined by EventTime",
+						"event1Name", history[i].EventName, "event2Name", history[j].EventName)
+
+					return !history[i].eventTime.Before(&history[j].eventTime)
+				}
+				// Timestamps are the same: attempt to use the event name.
+				// Conventionally (in client-go), the event name has a hexadecimal
+				// nanosecond timestamp as a suffix after a period.
+				iNameParts := strings.Split(history[i].EventName, ".")
+				jNameParts := strings.Split(history[j].EventName, ".")
+				errMsg := "Unable to interpret hexadecimal timestamp in event name, " +
+					"can't guarantee ordering of events in this status"
+
+				iNanos, err := strconv.ParseInt(iNameParts[len(iNameParts)-1], 16, 64)
+				if err != nil {
+					reqLogger.Error(err, errMsg, "eventName", history[i].EventName)
+
+					return false
+				}
+
+				jNanos, err := strconv.ParseInt(jNameParts[len(jNameParts)-1], 16, 64)
+				if err != nil {
+					reqLogger.Error(err, errMsg, "eventName", history[j].EventName)
+
+					return false
+				}
+
+				reqLogger.V(2).Info("Event timestamp collision, order determined by hex timestamp in name",
+					"event1Name", history[i].EventName, "event2Name", history[j].EventName)
+
+				return iNanos > jNanos
+			}
+
+			return !history[i].LastTimestamp.Time.Before(history[j].LastTimestamp.Time)
+		})
+		// remove duplicates
+		newHistory := []policiesv1.ComplianceHistory{}
+
+		for historyIndex := 0; historyIndex < len(history); historyIndex++ {
+			newHistory = append(newHistory, history[historyIndex].ComplianceHistory)
+
+			for j := historyIndex; j < len(history); j++ {
+				// Skip over duplicate statuses where the event name and message match the current status
+				if history[historyIndex].EventName != history[j].EventName ||
+					history[historyIndex].Message != history[j].Message {
+					historyIndex = j - 1
+
+					break
+				}
+			}
+		}
+		// shorten it to the history limit
+		size := r.HistoryLimit
+		if size <= 0 {
+			size = DefaultHistoryLimit
+		}
+
+		if len(newHistory) < size {
+			size = len(newHistory)
+		}
+
+		existingDpt.History = newHistory[0:size]
+
+		// set compliancy at different level
+		if len(existingDpt.History) > 0 {
+			existingDpt.ComplianceState = historyCompliance(existingDpt.History[0].Message)
+		}
+
+		staleAfter := r.checkStaleness(instance, existingDpt, object.GetObjectKind().GroupVersionKind().Kind, reqLogger)
+		if staleAfter > 0 && (staleRecheck == 0 || staleAfter < staleRecheck) {
+			staleRecheck = staleAfter
+		}
+
+		setTemplateTransition(existingDpt, previousState, newHistory)
+
+		// append existingDpt to status
+		newStatus.Details = append(newStatus.Details, existingDpt)
+
+		reqLogger.Info("Status update complete", "PolicyTemplate", tName)
+	}
+
+	// one violation found in status of one template, set overall compliancy to NonCompliant
+	isCompliant := true
+
+	for _, dpt := range newStatus.Details {
+		if dpt.ComplianceState == "NonCompliant" {
+			newStatus.ComplianceState = policiesv1.NonCompliant
+			isCompliant = false
+
+			break
+		} else if dpt.ComplianceState == "" || dpt.ComplianceState == ComplianceStateUnknown {
+			// a stale template leaves the overall compliancy unset since the hub only accepts Compliant and
+			// NonCompliant
+			isCompliant = false
+		}
+	}
+	// set to compliant only when all templates are compliant
+	if isCompliant {
+		newStatus.ComplianceState = policiesv1.Compliant
+	}
+
+	return newStatus, staleRecheck
+}
+
+// staleThreshold returns the staleness threshold of a policy template of the input kind in the input policy. The
+// annotation on the policy takes precedence over the threshold of the kind, which takes precedence over the default
+// threshold. Zero means the template never becomes stale.
+func (r *PolicyReconciler) staleThreshold(
+	instance *policiesv1.Policy, kind string, reqLogger logr.Logger,
+) time.Duration {
+	if value, found := instance.GetAnnotations()[StaleThresholdAnnotation]; found {
+		threshold, err := time.ParseDuration(value)
+		if err == nil {
+			return threshold
+		}
+
+		reqLogger.Error(err, "Ignoring the invalid staleness threshold annotation on the policy",
+			"annotation", StaleThresholdAnnotation, "value", value)
+	}
+
+	if threshold, found := r.StaleThresholdPerKind[kind]; found {
+		return threshold
+	}
+
+	return r.StaleThreshold
+}
+
+// checkStaleness sets the compliance state of the input policy template to Unknown when its newest history entry is
+// older than its staleness threshold. Otherwise, it returns the delay after which the template becomes stale, or
+// zero if it never does.
+func (r *PolicyReconciler) checkStaleness(
+	instance *policiesv1.Policy, dpt *policiesv1.DetailsPerTemplate, kind string, reqLogger logr.Logger,
+) time.Duration {
+	threshold := r.staleThreshold(instance, kind, reqLogger)
+	if threshold <= 0 || len(dpt.History) == 0 {
+		return 0
+	}
+
+	age := time.Since(dpt.History[0].LastTimestamp.Time)
+	if age < threshold {
+		return threshold - age
+	}
+
+	reqLogger.Info("The newest event of the policy template is older than the staleness threshold",
+		"PolicyTemplate", dpt.TemplateMeta.Name, "lastTimestamp", dpt.History[0].LastTimestamp.String(),
+		"threshold", threshold.String())
+
+	dpt.ComplianceState = ComplianceStateUnknown
+
+	return 0
+}
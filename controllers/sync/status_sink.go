@@ -0,0 +1,112 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// StatusSink writes the status computed on the managed cluster for a policy. The reconciler compares the computed
+// status with the status returned by ReadStatus, and only calls WriteStatus when they differ. The hub policy passed to
+// the sink is still retrieved from the hub, since its spec is synced to the managed policy.
+type StatusSink interface {
+	// ReadStatus returns the status last written for the input hub policy, which was just retrieved from the hub
+	ReadStatus(ctx context.Context, hubPlc *policiesv1.Policy) (policiesv1.PolicyStatus, error)
+	// WriteStatus writes the status of the input hub policy, which is already set to the computed status
+	WriteStatus(ctx context.Context, hubPlc *policiesv1.Policy) error
+}
+
+// HubAwareStatusSink is a StatusSink which uses the hub client or recorder, so it is given the new ones when they are
+// replaced with PolicyReconciler.SetHubClients.
+type HubAwareStatusSink interface {
+	StatusSink
+	// SetHubClients replaces the hub client and recorder of the sink. No status is read or written meanwhile.
+	SetHubClients(hubClient client.Client, hubRecorder record.EventRecorder)
+}
+
+// HubStatusSink is the default StatusSink, which updates the status of the policy on the hub and records an event on
+// it.
+type HubStatusSink struct {
+	Client   client.Client
+	Recorder record.EventRecorder
+}
+
+// ReadStatus returns the status of the input policy, which is the status on the hub since it was just retrieved.
+func (s *HubStatusSink) ReadStatus(_ context.Context, hubPlc *policiesv1.Policy) (policiesv1.PolicyStatus, error) {
+	return hubPlc.Status, nil
+}
+
+// SetHubClients replaces the hub client and recorder of the sink.
+func (s *HubStatusSink) SetHubClients(hubClient client.Client, hubRecorder record.EventRecorder) {
+	s.Client = hubClient
+	s.Recorder = hubRecorder
+}
+
+// WriteStatus updates the status of the input policy on the hub and records an event on it.
+func (s *HubStatusSink) WriteStatus(ctx context.Context, hubPlc *policiesv1.Policy) error {
+	if err := s.Client.Status().Update(ctx, hubPlc); err != nil {
+		return err
+	}
+
+	s.Recorder.Event(hubPlc, "Normal", "PolicyStatusSync",
+		fmt.Sprintf("Policy %s status was updated in cluster namespace %s", hubPlc.GetName(),
+			hubPlc.GetNamespace()))
+
+	return nil
+}
+
+// statusSink returns the StatusSink of the reconciler, which defaults to a HubStatusSink with the current hub client
+// and recorder. It must be called while holding the hub lock.
+func (r *PolicyReconciler) statusSink() StatusSink {
+	if r.StatusSink != nil {
+		return r.StatusSink
+	}
+
+	return &HubStatusSink{Client: r.HubClient, Recorder: r.HubRecorder}
+}
+
+// sinkOnly returns whether the reconciler has no hub client, so the statuses are only written to its status sink.
+func (r *PolicyReconciler) sinkOnly() bool {
+	return r.HubClient == nil
+}
+
+// sinkPolicy returns the policy passed to the status sink in place of the hub policy when there is no hub client: a
+// copy of the input managed policy in the cluster namespace on the hub, without its status.
+func (r *PolicyReconciler) sinkPolicy(instance *policiesv1.Policy) *policiesv1.Policy {
+	hubPlc := &policiesv1.Policy{
+		TypeMeta: instance.TypeMeta,
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        instance.GetName(),
+			Namespace:   r.ClusterNamespaceOnHub,
+			Labels:      instance.GetLabels(),
+			Annotations: instance.GetAnnotations(),
+			Generation:  instance.GetGeneration(),
+		},
+		Spec: instance.Spec,
+	}
+
+	return hubPlc.DeepCopy()
+}
+
+// readHubStatus returns the status last written for the input hub policy by the status sink. If it can't be read,
+// the failure is reported in the conditions of the managed policy. It must be called while holding the hub lock.
+func (r *PolicyReconciler) readHubStatus(
+	ctx context.Context, instance *policiesv1.Policy, hubPlc *policiesv1.Policy, conditions []metav1.Condition,
+) (policiesv1.PolicyStatus, error) {
+	status, err := r.statusSink().ReadStatus(ctx, hubPlc)
+	if err != nil {
+		log.Error(err, "Failed to read the policy status on hub",
+			"Request.Namespace", instance.GetNamespace(), "Request.Name", instance.GetName())
+		r.reportFailure(ctx, instance, append(conditions, syncedCondition("HubStatusReadFailed", "", err))...)
+
+		return policiesv1.PolicyStatus{}, err
+	}
+
+	return status, nil
+}
@@ -0,0 +1,202 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestHubStatusSink(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	if err := policiesv1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build the scheme: %v", err)
+	}
+
+	hubPlc := &policiesv1.Policy{ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "cluster"}}
+	hubClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(hubPlc).Build()
+	recorder := record.NewFakeRecorder(1)
+
+	r := &PolicyReconciler{HubClient: hubClient, HubRecorder: recorder}
+
+	hubPlc.Status.ComplianceState = policiesv1.NonCompliant
+	if err := r.statusSink().WriteStatus(context.TODO(), hubPlc); err != nil {
+		t.Fatalf("failed to write the status: %v", err)
+	}
+
+	written := &policiesv1.Policy{}
+	if err := hubClient.Get(context.TODO(), client.ObjectKeyFromObject(hubPlc), written); err != nil {
+		t.Fatalf("failed to get the policy: %v", err)
+	}
+
+	if written.Status.ComplianceState != policiesv1.NonCompliant {
+		t.Fatalf("expected the status to be written to the hub, got %v", written.Status)
+	}
+
+	if len(recorder.Events) != 1 {
+		t.Fatal("expected an event on the hub policy")
+	}
+}
+
+// memoryStatusSink is a StatusSink which keeps the written statuses in memory.
+type memoryStatusSink struct {
+	statuses map[string]policiesv1.PolicyStatus
+	writes   int
+}
+
+func (s *memoryStatusSink) ReadStatus(_ context.Context, hubPlc *policiesv1.Policy) (policiesv1.PolicyStatus, error) {
+	return s.statuses[hubPlc.GetName()], nil
+}
+
+func (s *memoryStatusSink) WriteStatus(_ context.Context, hubPlc *policiesv1.Policy) error {
+	s.statuses[hubPlc.GetName()] = hubPlc.Status
+	s.writes++
+
+	return nil
+}
+
+func TestStatusSinkWrites(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	if err := policiesv1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build the scheme: %v", err)
+	}
+
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build the scheme: %v", err)
+	}
+
+	managedPlc := &policiesv1.Policy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "managed"},
+		Spec: policiesv1.PolicySpec{
+			PolicyTemplates: []*policiesv1.PolicyTemplate{{
+				ObjectDefinition: runtime.RawExtension{
+					Raw: []byte(`{"kind":"ConfigurationPolicy","metadata":{"name":"template"}}`),
+				},
+			}},
+		},
+	}
+	hubPlc := managedPlc.DeepCopy()
+	hubPlc.SetNamespace("cluster")
+
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{Name: "event", Namespace: "managed"},
+		InvolvedObject: corev1.ObjectReference{
+			Kind: policiesv1.Kind, APIVersion: policiesv1APIVersion, Name: "policy",
+		},
+		Reason:        "policy: managed/template",
+		Message:       "NonCompliant; violation",
+		LastTimestamp: metav1.Now(),
+	}
+
+	tests := map[string]struct {
+		hubClient bool
+	}{
+		"with a hub client":    {hubClient: true},
+		"without a hub client": {hubClient: false},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			sink := &memoryStatusSink{statuses: map[string]policiesv1.PolicyStatus{}}
+			hubClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(hubPlc.DeepCopy()).Build()
+			options := []Option{
+				WithManaged(
+					fake.NewClientBuilder().WithScheme(scheme).WithObjects(managedPlc.DeepCopy(), event.DeepCopy()).
+						Build(),
+					record.NewFakeRecorder(10), scheme,
+				),
+				WithClusterNamespaceOnHub("cluster"),
+				WithStatusSink(sink),
+			}
+
+			if test.hubClient {
+				options = append(options, WithHub(hubClient, record.NewFakeRecorder(10)))
+			} else {
+				// the hub is never read, so the managed policy can't be recovered from it
+				options = append(options, WithStatusOnly(true))
+			}
+
+			r, err := NewPolicyReconciler(options...)
+			if err != nil {
+				t.Fatalf("failed to build the reconciler: %v", err)
+			}
+
+			key := client.ObjectKeyFromObject(managedPlc)
+
+			for i := 0; i < 3; i++ {
+				if _, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: key}); err != nil {
+					t.Fatalf("failed to reconcile: %v", err)
+				}
+			}
+
+			if sink.writes != 1 || sink.statuses["policy"].ComplianceState != policiesv1.NonCompliant {
+				t.Fatalf("expected a single write of the NonCompliant status, got %d writes of %+v",
+					sink.writes, sink.statuses)
+			}
+
+			written := &policiesv1.Policy{}
+			if err := hubClient.Get(context.TODO(), client.ObjectKeyFromObject(hubPlc), written); err != nil {
+				t.Fatalf("failed to get the hub policy: %v", err)
+			}
+
+			if written.Status.ComplianceState != "" {
+				t.Fatalf("expected the status to not be written to the hub, got %+v", written.Status)
+			}
+
+			conditions, err := r.conditions(context.TODO(), managedPlc)
+			if err != nil {
+				t.Fatalf("failed to get the conditions: %v", err)
+			}
+
+			hubReachable := meta.FindStatusCondition(conditions, ConditionHubReachable) != nil
+			if hubReachable != test.hubClient || !meta.IsStatusConditionTrue(conditions, ConditionSynced) {
+				t.Fatalf("expected the HubReachable condition only with a hub client, got %+v", conditions)
+			}
+		})
+	}
+}
+
+func TestStatusSinkWithoutHubClientRequiresStatusOnly(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewPolicyReconciler(
+		WithManaged(fake.NewClientBuilder().Build(), record.NewFakeRecorder(1), runtime.NewScheme()),
+		WithClusterNamespaceOnHub("cluster"),
+		WithStatusSink(&memoryStatusSink{}),
+	)
+	if err == nil || err.Error() != "the status-only mode is required without a hub client" {
+		t.Fatalf("expected only the status-only mode to be required, got %v", err)
+	}
+}
+
+func TestSetHubClientsStatusSink(t *testing.T) {
+	t.Parallel()
+
+	sink := &HubStatusSink{Client: fake.NewClientBuilder().Build(), Recorder: record.NewFakeRecorder(1)}
+	r := &PolicyReconciler{StatusSink: sink}
+
+	hubRecorder := record.NewFakeRecorder(1)
+	r.SetHubClients(fake.NewClientBuilder().Build(), hubRecorder)
+
+	if sink.Client != r.HubClient || sink.Recorder != hubRecorder {
+		t.Fatal("expected the status sink to be given the new hub clients")
+	}
+}
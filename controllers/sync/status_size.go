@@ -0,0 +1,304 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+)
+
+// DefaultStatusSizeBudget is the default maximum size in bytes of a policy with its status, below the 1.5 MiB default
+// request size limit of etcd
+const DefaultStatusSizeBudget = 1024 * 1024
+
+// StatusTruncatedAnnotation is the annotation, in the templateMeta of the status details of a policy template, that
+// describes how its history was reduced to fit the status size budget. It is synced to the hub with the status.
+const StatusTruncatedAnnotation = "policy.open-cluster-management.io/status-truncated"
+
+// ConditionStatusTruncated is the condition reporting whether the status was reduced to fit the status size budget.
+// It is only set when the budget is enabled.
+const ConditionStatusTruncated = "StatusTruncated"
+
+// minMessageLength is the shortest length to which the history messages are truncated
+const minMessageLength = 64
+
+// statusReduction is how a status is reduced to fit the size budget. Zero values leave the status unchanged.
+type statusReduction struct {
+	// historyLimit is the maximum number of history entries per policy template
+	historyLimit int
+	// messageLength is the maximum number of characters of a history message
+	messageLength int
+	// dropped is the number of oldest history entries dropped across the policy templates, after the history limit
+	dropped int
+}
+
+// String describes the reduction, for example "history limited to 2 entries, messages truncated to 128 characters".
+func (s statusReduction) String() string {
+	parts := []string{}
+
+	if s.historyLimit > 0 {
+		parts = append(parts, fmt.Sprintf("history limited to %d entries", s.historyLimit))
+	}
+
+	if s.messageLength > 0 {
+		parts = append(parts, fmt.Sprintf("messages truncated to %d characters", s.messageLength))
+	}
+
+	if s.dropped > 0 {
+		parts = append(parts, fmt.Sprintf("%d oldest entries dropped", s.dropped))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// reduceStatus returns a copy of the input status reduced as described by the input reduction, with the
+// StatusTruncatedAnnotation on the details of the changed policy templates.
+func reduceStatus(status policiesv1.PolicyStatus, reduction statusReduction) policiesv1.PolicyStatus {
+	reduced := *status.DeepCopy()
+	changed := map[*policiesv1.DetailsPerTemplate]bool{}
+
+	for _, dpt := range reduced.Details {
+		if dpt == nil {
+			continue
+		}
+
+		if reduction.historyLimit > 0 && len(dpt.History) > reduction.historyLimit {
+			dpt.History = dpt.History[:reduction.historyLimit]
+			changed[dpt] = true
+		}
+
+		for i := range dpt.History {
+			message := truncateMessage(dpt.History[i].Message, reduction.messageLength)
+			if message != dpt.History[i].Message {
+				dpt.History[i].Message = message
+				changed[dpt] = true
+			}
+		}
+	}
+
+	if reduction.dropped > 0 {
+		// the history of each template is sorted from the newest entry, so the oldest entry is the last one
+		for i := 0; i < reduction.dropped; i++ {
+			var oldest *policiesv1.DetailsPerTemplate
+
+			for _, dpt := range reduced.Details {
+				if dpt == nil || len(dpt.History) == 0 {
+					continue
+				}
+
+				if oldest == nil || dpt.History[len(dpt.History)-1].LastTimestamp.Before(
+					&oldest.History[len(oldest.History)-1].LastTimestamp) {
+					oldest = dpt
+				}
+			}
+
+			if oldest == nil {
+				break
+			}
+
+			oldest.History = oldest.History[:len(oldest.History)-1]
+			changed[oldest] = true
+		}
+	}
+
+	for dpt := range changed {
+		metav1.SetMetaDataAnnotation(&dpt.TemplateMeta, StatusTruncatedAnnotation, reduction.String())
+	}
+
+	return reduced
+}
+
+// historyEntries returns the number of history entries in the input status.
+func historyEntries(status policiesv1.PolicyStatus) int {
+	count := 0
+
+	for _, dpt := range status.Details {
+		if dpt != nil {
+			count += len(dpt.History)
+		}
+	}
+
+	return count
+}
+
+// jsonSize returns the size in bytes of the JSON representation of the input value.
+func jsonSize(value interface{}) int {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return 0
+	}
+
+	return len(content)
+}
+
+// sizeWithStatus returns a function returning the size in bytes of the JSON representation of the input policy with
+// another status. The rest of the policy is only marshaled once.
+func sizeWithStatus(plc *policiesv1.Policy) func(policiesv1.PolicyStatus) int {
+	withoutStatus := *plc
+	withoutStatus.Status = policiesv1.PolicyStatus{}
+	base := jsonSize(&withoutStatus) - jsonSize(withoutStatus.Status)
+
+	return func(status policiesv1.PolicyStatus) int {
+		return base + jsonSize(status)
+	}
+}
+
+// fitStatus reduces the status of the input managed policy until both the managed policy and the input hub policy,
+// with the status written to the hub, fit the status size budget: it shrinks the history of each policy template,
+// then truncates the messages, then drops the oldest entries, each time as little as needed. It returns the applied
+// reduction, nil if the status wasn't reduced, and the resulting size of the larger policy.
+func (r *PolicyReconciler) fitStatus(plc *policiesv1.Policy, hubPlc *policiesv1.Policy) (*statusReduction, int) {
+	// the truncation of a previous reconcile no longer applies since the history was merged again
+	for _, dpt := range plc.Status.Details {
+		if dpt == nil {
+			continue
+		}
+
+		delete(dpt.TemplateMeta.Annotations, StatusTruncatedAnnotation)
+
+		if len(dpt.TemplateMeta.Annotations) == 0 {
+			dpt.TemplateMeta.Annotations = nil
+		}
+	}
+
+	if r.StatusSizeBudget <= 0 {
+		return nil, 0
+	}
+
+	// the managed policy carries the managed-only annotations, such as the conditions, while the hub policy has its
+	// own metadata and a possibly differently redacted status
+	managedSize := sizeWithStatus(plc)
+	hubSize := sizeWithStatus(hubPlc)
+
+	policySize := func(status policiesv1.PolicyStatus) int {
+		size := managedSize(status)
+		if hub := hubSize(r.MessageRedaction.hubStatus(status)); hub > size {
+			return hub
+		}
+
+		return size
+	}
+
+	status := plc.Status
+
+	size := policySize(status)
+	if size <= r.StatusSizeBudget {
+		return nil, size
+	}
+
+	reduction := statusReduction{}
+
+	// fits returns whether the policies fit the budget with the status reduced by the reduction
+	fits := func() bool {
+		return policySize(reduceStatus(status, reduction)) <= r.StatusSizeBudget
+	}
+
+	// apply applies the reduction to the status of the policy
+	apply := func() (*statusReduction, int) {
+		plc.Status = reduceStatus(status, reduction)
+
+		return &reduction, policySize(plc.Status)
+	}
+
+	longestHistory := 0
+	longestMessage := 0
+
+	for _, dpt := range status.Details {
+		if dpt == nil {
+			continue
+		}
+
+		if len(dpt.History) > longestHistory {
+			longestHistory = len(dpt.History)
+		}
+
+		for _, entry := range dpt.History {
+			if length := len([]rune(entry.Message)); length > longestMessage {
+				longestMessage = length
+			}
+		}
+	}
+
+	// each search finds the least reduction that fits, since the status only gets smaller as it is reduced further
+	if longestHistory > 1 {
+		found := sort.Search(longestHistory-1, func(i int) bool {
+			reduction.historyLimit = longestHistory - 1 - i
+
+			return fits()
+		})
+		if found < longestHistory-1 {
+			reduction.historyLimit = longestHistory - 1 - found
+
+			return apply()
+		}
+
+		reduction.historyLimit = 1
+	}
+
+	if longestMessage > minMessageLength {
+		found := sort.Search(longestMessage-minMessageLength, func(i int) bool {
+			reduction.messageLength = longestMessage - 1 - i
+
+			return fits()
+		})
+		if found < longestMessage-minMessageLength {
+			reduction.messageLength = longestMessage - 1 - found
+
+			return apply()
+		}
+
+		reduction.messageLength = minMessageLength
+	}
+
+	// the entries left are dropped from the oldest; the compliance state of each policy template is kept
+	entries := historyEntries(reduceStatus(status, reduction))
+	found := sort.Search(entries, func(i int) bool {
+		reduction.dropped = i + 1
+
+		return fits()
+	})
+
+	// when nothing fits, the policy doesn't fit even without history and the status update will fail
+	reduction.dropped = found + 1
+	if found == entries {
+		reduction.dropped = entries
+	}
+
+	return apply()
+}
+
+// statusSizeCondition returns the StatusTruncated condition of the input reduction of the status, which resulted in
+// the input size of the policy.
+func (r *PolicyReconciler) statusSizeCondition(reduction *statusReduction, size int) metav1.Condition {
+	if reduction == nil {
+		return metav1.Condition{
+			Type:    ConditionStatusTruncated,
+			Status:  metav1.ConditionFalse,
+			Reason:  "WithinBudget",
+			Message: fmt.Sprintf("The policy fits the status size budget of %d bytes", r.StatusSizeBudget),
+		}
+	}
+
+	if size > r.StatusSizeBudget {
+		return metav1.Condition{
+			Type:   ConditionStatusTruncated,
+			Status: metav1.ConditionTrue,
+			Reason: "StatusSizeExceeded",
+			Message: fmt.Sprintf("The policy exceeds the status size budget of %d bytes even after the status "+
+				"was reduced (%s)", r.StatusSizeBudget, reduction),
+		}
+	}
+
+	return metav1.Condition{
+		Type:   ConditionStatusTruncated,
+		Status: metav1.ConditionTrue,
+		Reason: "StatusReduced",
+		Message: fmt.Sprintf("The status was reduced to fit the status size budget of %d bytes (%s)",
+			r.StatusSizeBudget, reduction),
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+)
+
+// sizedPolicy returns a policy with the input number of policy templates, each with 10 history entries with messages
+// of the input length, from the newest entry.
+func sizedPolicy(templates int, messageLength int) *policiesv1.Policy {
+	plc := &policiesv1.Policy{ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "managed"}}
+	now := time.Now()
+
+	for i := 0; i < templates; i++ {
+		dpt := &policiesv1.DetailsPerTemplate{
+			TemplateMeta: metav1.ObjectMeta{
+				Name: fmt.Sprintf("template-%d", i),
+				// left by a previous reconcile
+				Annotations: map[string]string{StatusTruncatedAnnotation: "history limited to 9 entries"},
+			},
+			ComplianceState: policiesv1.NonCompliant,
+		}
+
+		for j := 0; j < 10; j++ {
+			dpt.History = append(dpt.History, policiesv1.ComplianceHistory{
+				// the templates are ordered from the one with the oldest newest entry
+				LastTimestamp: metav1.NewTime(now.Add(-time.Duration(10*j+templates-i) * time.Minute)),
+				Message:       "NonCompliant; violation - " + strings.Repeat("x", messageLength),
+				EventName:     fmt.Sprintf("policy.%x", i*10+j),
+			})
+		}
+
+		plc.Status.Details = append(plc.Status.Details, dpt)
+	}
+
+	return plc
+}
+
+func TestFitStatus(t *testing.T) {
+	t.Parallel()
+
+	fullSize := jsonSize(sizedPolicy(4, 1000))
+
+	// the budget of a policy whose messages are truncated to exactly 256 characters
+	truncated := sizedPolicy(4, 1000)
+	truncated.Status = reduceStatus(truncated.Status, statusReduction{historyLimit: 1, messageLength: 256})
+	truncatedSize := jsonSize(truncated)
+
+	tests := map[string]struct {
+		budget    int
+		reduction *statusReduction
+		fits      bool
+	}{
+		"disabled":      {budget: 0},
+		"within budget": {budget: fullSize},
+		"history limit": {
+			budget: fullSize / 2, reduction: &statusReduction{historyLimit: 4}, fits: true,
+		},
+		"message truncation": {
+			budget: truncatedSize, reduction: &statusReduction{historyLimit: 1, messageLength: 256}, fits: true,
+		},
+		"oldest entries dropped": {
+			budget:    1500,
+			reduction: &statusReduction{historyLimit: 1, messageLength: minMessageLength, dropped: 2},
+			fits:      true,
+		},
+		"too large": {
+			budget: 10, reduction: &statusReduction{historyLimit: 1, messageLength: minMessageLength, dropped: 4},
+		},
+	}
+
+	for name, test := range tests {
+		name, test := name, test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r := &PolicyReconciler{StatusSizeBudget: test.budget}
+			plc := sizedPolicy(4, 1000)
+
+			hubPlc := &policiesv1.Policy{ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "cluster"}}
+
+			reduction, size := r.fitStatus(plc, hubPlc)
+			if (reduction == nil) != (test.reduction == nil) || reduction != nil && *reduction != *test.reduction {
+				t.Fatalf("expected the reduction %v, got %v", test.reduction, reduction)
+			}
+
+			if test.fits && (size > test.budget || jsonSize(plc) != size) {
+				t.Fatalf("expected the policy to fit the %d bytes budget, got %d bytes", test.budget, size)
+			}
+
+			for i, dpt := range plc.Status.Details {
+				annotation := dpt.TemplateMeta.Annotations[StatusTruncatedAnnotation]
+
+				if test.reduction == nil && annotation != "" {
+					t.Fatal("expected the previous truncation annotation to be removed")
+				}
+
+				if test.reduction != nil && len(dpt.History) > 0 && annotation != test.reduction.String() {
+					t.Fatalf("expected the truncation annotation %q, got %q", test.reduction.String(), annotation)
+				}
+
+				if len(dpt.History) > 0 && dpt.History[0].EventName != fmt.Sprintf("policy.%x", i*10) {
+					t.Fatalf("expected the newest entry of %s to be kept", dpt.TemplateMeta.Name)
+				}
+			}
+
+			if name == "oldest entries dropped" {
+				if len(plc.Status.Details[0].History) != 0 || len(plc.Status.Details[1].History) != 0 ||
+					len(plc.Status.Details[3].History) != 1 {
+					t.Fatal("expected the entries of the templates with the oldest newest entries to be dropped")
+				}
+			}
+		})
+	}
+}
+
+func TestFitStatusHubPolicy(t *testing.T) {
+	t.Parallel()
+
+	fullSize := jsonSize(sizedPolicy(4, 1000))
+
+	tests := map[string]struct {
+		managedAnnotation int
+		hubAnnotation     int
+	}{
+		// such as a managed-local annotation, which is only on the managed policy
+		"larger managed policy": {managedAnnotation: fullSize / 2},
+		"larger hub policy":     {hubAnnotation: fullSize / 2},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r := &PolicyReconciler{StatusSizeBudget: fullSize}
+			plc := sizedPolicy(4, 1000)
+			hubPlc := &policiesv1.Policy{ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "cluster"}}
+
+			if test.managedAnnotation > 0 {
+				metav1.SetMetaDataAnnotation(&plc.ObjectMeta, "managed", strings.Repeat("x", test.managedAnnotation))
+			}
+
+			if test.hubAnnotation > 0 {
+				metav1.SetMetaDataAnnotation(&hubPlc.ObjectMeta, "hub", strings.Repeat("x", test.hubAnnotation))
+			}
+
+			reduction, size := r.fitStatus(plc, hubPlc)
+			if reduction == nil || size > fullSize {
+				t.Fatalf("expected the status to be reduced to fit %d bytes, got %v and %d bytes", fullSize,
+					reduction, size)
+			}
+
+			hubPlc.Status = plc.Status
+			if managedSize, hubSize := jsonSize(plc), jsonSize(hubPlc); size != managedSize && size != hubSize ||
+				managedSize > size || hubSize > size {
+				t.Fatalf("expected the size %d to be the larger of %d and %d", size, managedSize, hubSize)
+			}
+
+			// the reduction is the least that fits
+			lighter := *reduction
+			lighter.historyLimit++
+
+			plc.Status = reduceStatus(sizedPolicy(4, 1000).Status, lighter)
+			hubPlc.Status = plc.Status
+
+			if jsonSize(plc) <= fullSize && jsonSize(hubPlc) <= fullSize {
+				t.Fatalf("expected the reduction %v to be the least that fits, %v also fits", reduction, lighter)
+			}
+		})
+	}
+}
+
+func TestStatusSizeCondition(t *testing.T) {
+	t.Parallel()
+
+	r := &PolicyReconciler{StatusSizeBudget: 1000}
+
+	condition := r.statusSizeCondition(&statusReduction{historyLimit: 2, messageLength: 128}, 900)
+	if condition.Status != metav1.ConditionTrue || condition.Reason != "StatusReduced" ||
+		!strings.Contains(condition.Message, "history limited to 2 entries, messages truncated to 128 characters") {
+		t.Fatalf("unexpected condition: %+v", condition)
+	}
+
+	condition = r.statusSizeCondition(&statusReduction{dropped: 3}, 1100)
+	if condition.Reason != "StatusSizeExceeded" {
+		t.Fatalf("unexpected condition: %+v", condition)
+	}
+
+	if condition := r.statusSizeCondition(nil, 900); condition.Status != metav1.ConditionFalse {
+		t.Fatalf("unexpected condition: %+v", condition)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+)
+
+func TestCheckStaleness(t *testing.T) {
+	t.Parallel()
+
+	r := &PolicyReconciler{
+		StaleThreshold:        time.Hour,
+		StaleThresholdPerKind: map[string]time.Duration{"CertificatePolicy": 3 * time.Hour},
+	}
+
+	tests := map[string]struct {
+		annotation string
+		kind       string
+		age        time.Duration
+		wantStale  bool
+	}{
+		"fresh with the default threshold": {kind: "ConfigurationPolicy", age: time.Minute},
+		"stale with the default threshold": {kind: "ConfigurationPolicy", age: 2 * time.Hour, wantStale: true},
+		"fresh with the kind threshold":    {kind: "CertificatePolicy", age: 2 * time.Hour},
+		"stale with the annotation": {
+			annotation: "10m", kind: "CertificatePolicy", age: 20 * time.Minute, wantStale: true,
+		},
+		"invalid annotation falls back": {annotation: "soon", kind: "ConfigurationPolicy", age: time.Minute},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			instance := &policiesv1.Policy{}
+			if test.annotation != "" {
+				instance.SetAnnotations(map[string]string{StaleThresholdAnnotation: test.annotation})
+			}
+
+			dpt := &policiesv1.DetailsPerTemplate{
+				ComplianceState: policiesv1.Compliant,
+				History: []policiesv1.ComplianceHistory{
+					{LastTimestamp: metav1.NewTime(time.Now().Add(-test.age))},
+				},
+			}
+
+			recheck := r.checkStaleness(instance, dpt, test.kind, logr.Discard())
+
+			if test.wantStale {
+				if dpt.ComplianceState != ComplianceStateUnknown || recheck != 0 {
+					t.Fatalf("expected a stale template, got state %s and recheck %s", dpt.ComplianceState, recheck)
+				}
+
+				return
+			}
+
+			if dpt.ComplianceState != policiesv1.Compliant || recheck <= 0 {
+				t.Fatalf("expected a fresh template, got state %s and recheck %s", dpt.ComplianceState, recheck)
+			}
+		})
+	}
+}
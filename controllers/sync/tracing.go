@@ -0,0 +1,163 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// Clusters of the API calls in the trace spans
+const (
+	hubCluster     = "hub"
+	managedCluster = "managed"
+)
+
+// tracer returns the tracer of the controller, from the global tracer provider. The spans are dropped unless a
+// tracer provider is configured.
+func tracer() trace.Tracer {
+	return otel.Tracer(ControllerName)
+}
+
+// tracingClient is a client that records a child span of the span in the context, such as the span of the reconcile,
+// for each API call.
+type tracingClient struct {
+	client.Client
+	cluster string
+}
+
+// traceClient returns a client recording a span for each API call of the input client to the input cluster, unless
+// it already does.
+func traceClient(c client.Client, cluster string) client.Client {
+	if c == nil {
+		return nil
+	}
+
+	if _, traced := c.(*tracingClient); traced {
+		return c
+	}
+
+	return &tracingClient{Client: c, cluster: cluster}
+}
+
+// startSpan starts the span of an API call with the input verb on the input object or list, and in the input
+// namespace.
+func (c *tracingClient) startSpan(
+	ctx context.Context, verb string, obj runtime.Object, namespace string, name string,
+) (context.Context, trace.Span) {
+	kind := ""
+	if gvk, err := apiutil.GVKForObject(obj, c.Scheme()); err == nil {
+		kind = gvk.Kind
+	}
+
+	attributes := []attribute.KeyValue{
+		attribute.String("k8s.cluster", c.cluster),
+		attribute.String("k8s.kind", kind),
+		attribute.String("k8s.namespace.name", namespace),
+	}
+
+	if name != "" {
+		attributes = append(attributes, attribute.String("k8s.object.name", name))
+	}
+
+	return tracer().Start(ctx, c.cluster+" "+verb+" "+kind,
+		trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+}
+
+// endSpan ends the span of an API call, recording its error, if any.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+func (c *tracingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	ctx, span := c.startSpan(ctx, "Get", obj, key.Namespace, key.Name)
+	err := c.Client.Get(ctx, key, obj)
+	endSpan(span, err)
+
+	return err
+}
+
+func (c *tracingClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	listOpts := &client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+
+	ctx, span := c.startSpan(ctx, "List", list, listOpts.Namespace, "")
+	err := c.Client.List(ctx, list, opts...)
+	endSpan(span, err)
+
+	return err
+}
+
+func (c *tracingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	ctx, span := c.startSpan(ctx, "Create", obj, obj.GetNamespace(), obj.GetName())
+	err := c.Client.Create(ctx, obj, opts...)
+	endSpan(span, err)
+
+	return err
+}
+
+func (c *tracingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	ctx, span := c.startSpan(ctx, "Delete", obj, obj.GetNamespace(), obj.GetName())
+	err := c.Client.Delete(ctx, obj, opts...)
+	endSpan(span, err)
+
+	return err
+}
+
+func (c *tracingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	ctx, span := c.startSpan(ctx, "Update", obj, obj.GetNamespace(), obj.GetName())
+	err := c.Client.Update(ctx, obj, opts...)
+	endSpan(span, err)
+
+	return err
+}
+
+func (c *tracingClient) Patch(
+	ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption,
+) error {
+	ctx, span := c.startSpan(ctx, "Patch", obj, obj.GetNamespace(), obj.GetName())
+	err := c.Client.Patch(ctx, obj, patch, opts...)
+	endSpan(span, err)
+
+	return err
+}
+
+func (c *tracingClient) Status() client.StatusWriter {
+	return &tracingStatusWriter{StatusWriter: c.Client.Status(), client: c}
+}
+
+// tracingStatusWriter records a span for each status update of its client.
+type tracingStatusWriter struct {
+	client.StatusWriter
+	client *tracingClient
+}
+
+func (w *tracingStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	ctx, span := w.client.startSpan(ctx, "UpdateStatus", obj, obj.GetNamespace(), obj.GetName())
+	err := w.StatusWriter.Update(ctx, obj, opts...)
+	endSpan(span, err)
+
+	return err
+}
+
+func (w *tracingStatusWriter) Patch(
+	ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption,
+) error {
+	ctx, span := w.client.startSpan(ctx, "PatchStatus", obj, obj.GetNamespace(), obj.GetName())
+	err := w.StatusWriter.Patch(ctx, obj, patch, opts...)
+	endSpan(span, err)
+
+	return err
+}
@@ -0,0 +1,68 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// TestTracingClient isn't parallel since it sets the global tracer provider.
+func TestTracingClient(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	scheme := runtime.NewScheme()
+	if err := policiesv1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build the scheme: %v", err)
+	}
+
+	hubPlc := &policiesv1.Policy{ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "cluster"}}
+	hubClient := traceClient(fake.NewClientBuilder().WithScheme(scheme).WithObjects(hubPlc).Build(), hubCluster)
+
+	if traceClient(hubClient, hubCluster) != hubClient {
+		t.Fatal("expected a traced client not to be wrapped again")
+	}
+
+	ctx, parent := tracer().Start(context.TODO(), "Reconcile")
+
+	if err := hubClient.Get(ctx, client.ObjectKeyFromObject(hubPlc), &policiesv1.Policy{}); err != nil {
+		t.Fatalf("failed to get the policy: %v", err)
+	}
+
+	missing := client.ObjectKey{Namespace: "cluster", Name: "missing"}
+	if err := hubClient.Get(ctx, missing, &policiesv1.Policy{}); err == nil {
+		t.Fatal("expected the missing policy not to be found")
+	}
+
+	if err := hubClient.Status().Update(ctx, hubPlc); err != nil {
+		t.Fatalf("failed to update the policy status: %v", err)
+	}
+
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 4 {
+		t.Fatalf("expected 4 spans, got %d", len(spans))
+	}
+
+	for i, name := range []string{"hub Get Policy", "hub Get Policy", "hub UpdateStatus Policy"} {
+		if spans[i].Name() != name || spans[i].Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Fatalf("expected the child span %q of the reconcile span, got %q", name, spans[i].Name())
+		}
+	}
+
+	if spans[0].Status().Code == codes.Error || spans[1].Status().Code != codes.Error {
+		t.Fatal("expected only the failed API call to have an error status")
+	}
+}
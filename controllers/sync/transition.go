@@ -0,0 +1,181 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	gosync "sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+)
+
+// LastTransitionTimeAnnotation is the annotation in the templateMeta of a policy template status in which the time
+// of the last change of its compliance state is recorded, in the RFC 3339 format. Unlike the history, it is kept
+// when the history is truncated.
+const LastTransitionTimeAnnotation = "policy.open-cluster-management.io/last-transition-time"
+
+// ConditionCompliant reflects the overall compliance state of the policy: true when Compliant, false when
+// NonCompliant and unknown otherwise. Its last transition time is the time of the last change of the overall
+// compliance state.
+const ConditionCompliant = "Compliant"
+
+// complianceRunStart returns the timestamp of the oldest history entry, newest entry first, that reports the same
+// compliance state as the newest entry without interruption.
+func complianceRunStart(history []policiesv1.ComplianceHistory) metav1.Time {
+	start := history[0].LastTimestamp
+	state := historyCompliance(history[0].Message)
+
+	for _, entry := range history[1:] {
+		if historyCompliance(entry.Message) != state {
+			break
+		}
+
+		start = entry.LastTimestamp
+	}
+
+	return start
+}
+
+// templateTransitionTime returns the last transition time recorded on the input policy template status.
+func templateTransitionTime(dpt *policiesv1.DetailsPerTemplate) (time.Time, bool) {
+	value, found := dpt.TemplateMeta.GetAnnotations()[LastTransitionTimeAnnotation]
+	if !found {
+		return time.Time{}, false
+	}
+
+	transition, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return transition, true
+}
+
+// setTemplateTransition records the last transition time of the input policy template status, whose compliance state
+// was previously the input state. The merged history, before it is truncated, determines when the current state
+// started. The recorded time is kept as long as the state doesn't change, even after the entries it was determined
+// from are truncated.
+func setTemplateTransition(
+	dpt *policiesv1.DetailsPerTemplate, previousState policiesv1.ComplianceState,
+	history []policiesv1.ComplianceHistory,
+) {
+	if dpt.ComplianceState == "" {
+		return
+	}
+
+	if _, found := templateTransitionTime(dpt); found && dpt.ComplianceState == previousState {
+		return
+	}
+
+	transition := metav1.Now()
+	if dpt.ComplianceState != ComplianceStateUnknown && len(history) > 0 {
+		transition = complianceRunStart(history)
+	}
+
+	annotations := dpt.TemplateMeta.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+
+	annotations[LastTransitionTimeAnnotation] = transition.UTC().Format(time.RFC3339)
+	dpt.TemplateMeta.SetAnnotations(annotations)
+}
+
+// complianceCondition returns the Compliant condition of the input status. Its last transition time is the newest
+// transition of a policy template, which is only used when the overall compliance state changes.
+func complianceCondition(status policiesv1.PolicyStatus) metav1.Condition {
+	condition := metav1.Condition{
+		Type:    ConditionCompliant,
+		Status:  metav1.ConditionUnknown,
+		Reason:  "NoStatus",
+		Message: "The compliance state of the policy is not known",
+	}
+
+	switch status.ComplianceState {
+	case policiesv1.Compliant:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = string(policiesv1.Compliant)
+		condition.Message = "All the policy templates are compliant"
+	case policiesv1.NonCompliant:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = string(policiesv1.NonCompliant)
+		condition.Message = "At least one policy template is not compliant"
+	}
+
+	for _, dpt := range status.Details {
+		transition, found := templateTransitionTime(dpt)
+		if found && transition.After(condition.LastTransitionTime.Time) {
+			condition.LastTransitionTime = metav1.NewTime(transition)
+		}
+	}
+
+	return condition
+}
+
+// transitionMetrics reports the last transition times of the policies and their templates, and remembers the
+// reported series so they can be deleted when a template or policy is removed.
+type transitionMetrics struct {
+	lock      gosync.Mutex
+	templates map[types.NamespacedName]map[string]policiesv1.ComplianceState
+}
+
+// report sets the last transition time metrics of the input policy from its status and conditions.
+func (m *transitionMetrics) report(key types.NamespacedName, plc *policiesv1.Policy, conditions []metav1.Condition) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.templates == nil {
+		m.templates = map[types.NamespacedName]map[string]policiesv1.ComplianceState{}
+	}
+
+	reported := m.templates[key]
+	current := make(map[string]policiesv1.ComplianceState, len(plc.Status.Details))
+
+	for _, dpt := range plc.Status.Details {
+		transition, found := templateTransitionTime(dpt)
+		if !found {
+			continue
+		}
+
+		name := dpt.TemplateMeta.Name
+		if state, found := reported[name]; found && state != dpt.ComplianceState {
+			templateTransitionGauge.DeleteLabelValues(key.Namespace, key.Name, name, string(state))
+		}
+
+		templateTransitionGauge.WithLabelValues(key.Namespace, key.Name, name, string(dpt.ComplianceState)).Set(
+			float64(transition.Unix()),
+		)
+		current[name] = dpt.ComplianceState
+	}
+
+	for name, state := range reported {
+		if _, found := current[name]; !found {
+			templateTransitionGauge.DeleteLabelValues(key.Namespace, key.Name, name, string(state))
+		}
+	}
+
+	m.templates[key] = current
+
+	condition := meta.FindStatusCondition(conditions, ConditionCompliant)
+	if condition != nil {
+		policyTransitionGauge.WithLabelValues(key.Namespace, key.Name).Set(
+			float64(condition.LastTransitionTime.Unix()),
+		)
+	}
+}
+
+// forget deletes the last transition time metrics of the input policy, because it was deleted.
+func (m *transitionMetrics) forget(key types.NamespacedName) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for name, state := range m.templates[key] {
+		templateTransitionGauge.DeleteLabelValues(key.Namespace, key.Name, name, string(state))
+	}
+
+	delete(m.templates, key)
+	policyTransitionGauge.DeleteLabelValues(key.Namespace, key.Name)
+}
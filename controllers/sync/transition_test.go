@@ -0,0 +1,53 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package sync
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
+)
+
+func TestSetTemplateTransition(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	history := []policiesv1.ComplianceHistory{
+		{LastTimestamp: metav1.NewTime(now), Message: "NonCompliant; violation - missing"},
+		{LastTimestamp: metav1.NewTime(now.Add(-time.Minute)), Message: "NonCompliant; violation - missing"},
+		{LastTimestamp: metav1.NewTime(now.Add(-2 * time.Minute)), Message: "Compliant; notification - ok"},
+	}
+	runStart := now.Add(-time.Minute).Format(time.RFC3339)
+	recorded := now.Add(-time.Hour).Format(time.RFC3339)
+
+	tests := map[string]struct {
+		previousState policiesv1.ComplianceState
+		recorded      string
+		want          string
+	}{
+		"first status":    {want: runStart},
+		"state changed":   {previousState: policiesv1.Compliant, recorded: recorded, want: runStart},
+		"state unchanged": {previousState: policiesv1.NonCompliant, recorded: recorded, want: recorded},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dpt := &policiesv1.DetailsPerTemplate{ComplianceState: policiesv1.NonCompliant}
+			if test.recorded != "" {
+				dpt.TemplateMeta.SetAnnotations(map[string]string{LastTransitionTimeAnnotation: test.recorded})
+			}
+
+			setTemplateTransition(dpt, test.previousState, history)
+
+			if got := dpt.TemplateMeta.GetAnnotations()[LastTransitionTimeAnnotation]; got != test.want {
+				t.Fatalf("expected the last transition time %s, got %s", test.want, got)
+			}
+		})
+	}
+}
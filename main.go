@@ -5,12 +5,10 @@ package main
 
 import (
 	"context"
-	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"runtime"
-	"strings"
 
 	"github.com/go-logr/zapr"
 	"github.com/spf13/pflag"
@@ -20,25 +18,12 @@ import (
 	v1 "k8s.io/api/core/v1"
 	k8sruntime "k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
-	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
-	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
-	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
-	"open-cluster-management.io/addon-framework/pkg/lease"
-	addonutils "open-cluster-management.io/addon-framework/pkg/utils"
 	policiesv1 "open-cluster-management.io/governance-policy-propagator/api/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/cache"
-	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/client/config"
-	"sigs.k8s.io/controller-runtime/pkg/healthz"
-	"sigs.k8s.io/controller-runtime/pkg/manager"
 
-	"open-cluster-management.io/governance-policy-status-sync/controllers/sync"
 	"open-cluster-management.io/governance-policy-status-sync/tool"
 	"open-cluster-management.io/governance-policy-status-sync/version"
 )
@@ -83,191 +68,93 @@ func main() {
 
 	pflag.Parse()
 
-	ctrlZap, err := zflags.BuildForCtrl()
+	// The loggers are built with levels that can be changed at runtime, per logger name
+	logLevels, err := tool.NewLogLevels(zflags.GetConfig().Level.Level(), flag.CommandLine.Lookup("v"))
+	if err != nil {
+		panic(fmt.Sprintf("Failed to configure the log levels: %v", err))
+	}
+
+	ctrlZap, err := logLevels.Build(zflags.GetConfig())
 	if err != nil {
 		panic(fmt.Sprintf("Failed to build zap logger for controller: %v", err))
 	}
 
 	ctrl.SetLogger(zapr.NewLogger(ctrlZap))
 
-	klogZap, err := zaputil.BuildForKlog(zflags.GetConfig(), flag.CommandLine)
+	klogZapCfg := zflags.GetConfig()
+	if klogZapCfg.Encoding == "console" {
+		// klog already ends its messages with a new line
+		klogZapCfg.EncoderConfig.SkipLineEnding = true
+	}
+
+	klogZap, err := logLevels.Build(klogZapCfg)
 	if err != nil {
 		log.Error(err, "Failed to build zap logger for klog, those logs will not go through zap")
 	} else {
-		klog.SetLogger(zapr.NewLogger(klogZap).WithName("klog"))
+		klog.SetLogger(zapr.NewLogger(klogZap).WithName(tool.KlogLoggerName))
 	}
 
 	printVersion()
 
-	// Get hubconfig to talk to hub apiserver
-	if tool.Options.HubConfigFilePathName == "" {
-		var found bool
-
-		tool.Options.HubConfigFilePathName, found = os.LookupEnv("HUB_CONFIG")
-		if found {
-			log.Info("Found ENV HUB_CONFIG, initializing using", "tool.Options.HubConfigFilePathName",
-				tool.Options.HubConfigFilePathName)
-		}
-	}
-
-	hubCfg, err := clientcmd.BuildConfigFromFlags("", tool.Options.HubConfigFilePathName)
+	setup, err := tool.NewSetup(scheme, eventsScheme)
 	if err != nil {
-		log.Error(err, "Failed to build hub cluster config")
+		log.Error(err, "Failed to configure the controller")
 		os.Exit(1)
 	}
 
-	// Get managedconfig to talk to managed apiserver
-	var managedCfg *rest.Config
-
-	if tool.Options.ManagedConfigFilePathName == "" {
-		var found bool
-
-		tool.Options.ManagedConfigFilePathName, found = os.LookupEnv("MANAGED_CONFIG")
-		if found {
-			log.Info("Found ENV MANAGED_CONFIG, initializing using", "tool.Options.ManagedConfigFilePathName",
-				tool.Options.ManagedConfigFilePathName)
-
-			managedCfg, err = clientcmd.BuildConfigFromFlags("", tool.Options.ManagedConfigFilePathName)
-			if err != nil {
-				log.Error(err, "Failed to build managed cluster config")
-				os.Exit(1)
-			}
-		} else {
-			managedCfg, err = config.GetConfig()
-			if err != nil {
-				log.Error(err, "Failed to build managed cluster config")
-				os.Exit(1)
-			}
+	switch pflag.Arg(0) {
+	case "preflight":
+		// The preflight subcommand only reports the RBAC permissions and exits
+		passed, err := setup.RunPreflight(context.TODO(), os.Stdout)
+		if err != nil {
+			log.Error(err, "Failed to verify the RBAC permissions")
+			os.Exit(1)
 		}
-	}
-
-	hubClient, err := client.New(hubCfg, client.Options{Scheme: scheme})
-	if err != nil {
-		log.Error(err, "Failed to generate client to the hub cluster")
-		os.Exit(1)
-	}
-	var kubeClient kubernetes.Interface = kubernetes.NewForConfigOrDie(hubCfg)
-
-	eventBroadcaster := record.NewBroadcaster()
-
-	namespace, err := tool.GetWatchNamespace()
-	if err != nil {
-		log.Error(err, "Failed to get watch namespace")
-		os.Exit(1)
-	}
-
-	var clusterNamespaceOnHub string
-	if tool.Options.ClusterNamespaceOnHub == "" {
-		clusterNamespaceOnHub = namespace
-	} else {
-		clusterNamespaceOnHub = tool.Options.ClusterNamespaceOnHub
-		log.Info(
-			"The Hub will receive status updates in the input cluster namespace", "namespace", clusterNamespaceOnHub,
-		)
-	}
 
-	eventBroadcaster.StartRecordingToSink(
-		&corev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events(clusterNamespaceOnHub)},
-	)
-
-	hubRecorder := eventBroadcaster.NewRecorder(eventsScheme, v1.EventSource{Component: sync.ControllerName})
-
-	options := manager.Options{
-		LeaderElection:         tool.Options.EnableLeaderElection,
-		LeaderElectionID:       "policy-status-sync.open-cluster-management.io",
-		HealthProbeBindAddress: tool.Options.ProbeAddr,
-		// Disable the metrics endpoint
-		MetricsBindAddress: "0",
-		Namespace:          namespace,
-		Scheme:             scheme,
-	}
-	if tool.Options.LegacyLeaderElection {
-		// If legacyLeaderElection is enabled, then that means the lease API is not available.
-		// In this case, use the legacy leader election method of a ConfigMap.
-		options.LeaderElectionResourceLock = "configmaps"
-	} else {
-		// use the leases leader election by default for controller-runtime 0.11 instead of
-		// the default of configmapsleases (leases is the new default in 0.12)
-		options.LeaderElectionResourceLock = "leases"
-	}
-	// Add support for MultiNamespace set in WATCH_NAMESPACE (e.g ns1,ns2)
-	// Note that this is not intended to be used for excluding namespaces, this is better done via a Predicate
-	// Also note that you may face performance issues when using this with a high number of namespaces.
-	// More Info: https://godoc.org/github.com/kubernetes-sigs/controller-runtime/pkg/cache#MultiNamespacedCacheBuilder
-	if strings.Contains(namespace, ",") {
-		options.Namespace = ""
-		options.NewCache = cache.MultiNamespacedCacheBuilder(strings.Split(namespace, ","))
-	}
-
-	mgr, err := ctrl.NewManager(managedCfg, options)
-	if err != nil {
-		log.Error(err, "unable to start manager")
-		os.Exit(1)
-	}
-
-	if err = (&sync.PolicyReconciler{
-		ClusterNamespaceOnHub: clusterNamespaceOnHub,
-		HubClient:             hubClient,
-		HubRecorder:           hubRecorder,
-		ManagedClient:         mgr.GetClient(),
-		ManagedRecorder:       mgr.GetEventRecorderFor(sync.ControllerName),
-		Scheme:                mgr.GetScheme(),
-	}).SetupWithManager(mgr); err != nil {
-		log.Error(err, "unable to create controller", "controller", "Policy")
-		os.Exit(1)
-	}
+		if !passed {
+			os.Exit(1)
+		}
 
-	// use config check
-	configChecker, err := addonutils.NewConfigChecker("policy-status-sync", tool.Options.HubConfigFilePathName)
-	if err != nil {
-		log.Error(err, "unable to setup a configChecker")
-		os.Exit(1)
-	}
+		os.Exit(0)
+	case "inspect":
+		// The inspect subcommand only reports the status computed for a policy and exits
+		if pflag.Arg(1) == "" {
+			log.Info("Usage: inspect <policy>, with a single watch namespace")
+			os.Exit(1)
+		}
 
-	//+kubebuilder:scaffold:builder
-	if err := mgr.AddHealthzCheck("healthz", configChecker.Check); err != nil {
-		log.Error(err, "unable to set up health check")
-		os.Exit(1)
-	}
+		if err := setup.RunInspect(context.TODO(), pflag.Arg(1), os.Stdout); err != nil {
+			log.Error(err, "Failed to inspect the policy", "policy", pflag.Arg(1))
+			os.Exit(1)
+		}
 
-	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
-		log.Error(err, "unable to set up ready check")
-		os.Exit(1)
+		os.Exit(0)
 	}
 
-	// This lease is not related to leader election. This is to report the status of the controller
-	// to the addon framework. This can be seen in the "status" section of the ManagedClusterAddOn
-	// resource objects.
-	if tool.Options.EnableLease {
-		ctx := context.TODO()
-
-		operatorNs, err := tool.GetOperatorNamespace()
+	switch tool.Options.Preflight {
+	case tool.PreflightNone:
+	case tool.PreflightWarn, tool.PreflightFail:
+		passed, err := setup.RunPreflight(context.TODO(), tool.ConsoleOutput())
 		if err != nil {
-			if errors.Is(err, tool.ErrNoNamespace) || errors.Is(err, tool.ErrRunLocal) {
-				log.Info("Skipping lease; not running in a cluster.")
-			} else {
-				log.Error(err, "Failed to get operator namespace")
+			log.Error(err, "Failed to verify the RBAC permissions")
+			os.Exit(1)
+		}
+
+		if !passed {
+			if tool.Options.Preflight == tool.PreflightFail {
+				log.Info("Exiting since the RBAC permissions are missing")
 				os.Exit(1)
 			}
-		} else {
-			log.Info("Starting lease controller to report status")
-			generatedClient := kubernetes.NewForConfigOrDie(managedCfg)
-			leaseUpdater := lease.NewLeaseUpdater(
-				generatedClient,
-				"governance-policy-framework",
-				operatorNs,
-				lease.CheckAddonPodFunc(generatedClient.CoreV1(), operatorNs, "app=governance-policy-framework"),
-			).WithHubLeaseConfig(hubCfg, namespace)
-			go leaseUpdater.Start(ctx)
+
+			log.Info("Some RBAC permissions are missing, policy statuses may fail to sync")
 		}
-	} else {
-		log.Info("Status reporting is not enabled")
+	default:
+		log.Error(fmt.Errorf("invalid value %q", tool.Options.Preflight), "Invalid --preflight flag")
+		os.Exit(1)
 	}
 
-	log.Info("starting manager")
-
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
-		log.Error(err, "problem running manager")
+	if err := setup.Run(ctrl.SetupSignalHandler(), logLevels); err != nil {
+		log.Error(err, "Failed to run the controller")
 		os.Exit(1)
 	}
 }
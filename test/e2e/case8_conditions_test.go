@@ -0,0 +1,119 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"open-cluster-management.io/governance-policy-propagator/test/utils"
+
+	"open-cluster-management.io/governance-policy-status-sync/controllers/sync"
+)
+
+const (
+	case8PolicyName string = "default.case8-test-policy"
+	case8PolicyYaml string = "../resources/case8_conditions/case8-test-policy.yaml"
+)
+
+// getCondition returns the status and reason of the condition of the input type in the conditions ConfigMap of the
+// managed policy
+func getCondition(name string, conditionType string) func() []string {
+	return func() []string {
+		configMap, err := clientManaged.CoreV1().ConfigMaps(testNamespace).Get(
+			context.TODO(), sync.ConditionsConfigMapName(name), metav1.GetOptions{},
+		)
+		if err != nil {
+			return nil
+		}
+
+		conditions := []metav1.Condition{}
+
+		err = json.Unmarshal([]byte(configMap.Data[sync.ConditionsKey]), &conditions)
+		if err != nil {
+			return nil
+		}
+
+		condition := meta.FindStatusCondition(conditions, conditionType)
+		if condition == nil {
+			return nil
+		}
+
+		return []string{string(condition.Status), condition.Reason}
+	}
+}
+
+var _ = Describe("Test sync conditions on the managed policy", func() {
+	BeforeEach(func() {
+		By("Creating a policy on hub cluster in ns:" + clusterNamespaceOnHub)
+		_, err := utils.KubectlWithOutput("apply", "-f", case8PolicyYaml, "-n", clusterNamespaceOnHub,
+			"--kubeconfig=../../kubeconfig_hub")
+		Expect(err).ShouldNot(HaveOccurred())
+		hubPlc := utils.GetWithTimeout(
+			clientHubDynamic, gvrPolicy, case8PolicyName, clusterNamespaceOnHub, true, defaultTimeoutSeconds,
+		)
+		Expect(hubPlc).NotTo(BeNil())
+		By("Creating a policy on managed cluster in ns:" + testNamespace)
+		_, err = utils.KubectlWithOutput("apply", "-f", case8PolicyYaml, "-n", testNamespace,
+			"--kubeconfig=../../kubeconfig_managed")
+		Expect(err).ShouldNot(HaveOccurred())
+		managedPlc := utils.GetWithTimeout(
+			clientManagedDynamic, gvrPolicy, case8PolicyName, testNamespace, true, defaultTimeoutSeconds,
+		)
+		Expect(managedPlc).NotTo(BeNil())
+	})
+	AfterEach(func() {
+		By("Deleting a policy on hub cluster in ns:" + clusterNamespaceOnHub)
+		_, err := utils.KubectlWithOutput("delete", "-f", case8PolicyYaml, "-n", clusterNamespaceOnHub,
+			"--kubeconfig=../../kubeconfig_hub")
+		Expect(err).ShouldNot(HaveOccurred())
+		_, err = utils.KubectlWithOutput("delete", "-f", case8PolicyYaml, "-n", testNamespace,
+			"--ignore-not-found", "--kubeconfig=../../kubeconfig_managed")
+		Expect(err).ShouldNot(HaveOccurred())
+		opt := metav1.ListOptions{}
+		utils.ListWithTimeout(clientHubDynamic, gvrPolicy, opt, 0, true, defaultTimeoutSeconds)
+		utils.ListWithTimeout(clientManagedDynamic, gvrPolicy, opt, 0, true, defaultTimeoutSeconds)
+		By("clean up all events")
+		_, err = utils.KubectlWithOutput("delete", "events", "-n", testNamespace, "--all",
+			"--kubeconfig=../../kubeconfig_managed")
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+	It("Should set the Synced and HubReachable conditions after the status is synced", func() {
+		By("Generating a compliant event on the managed policy")
+		managedPlc := utils.GetWithTimeout(
+			clientManagedDynamic, gvrPolicy, case8PolicyName, testNamespace, true, defaultTimeoutSeconds,
+		)
+		managedRecorder.Event(
+			managedPlc,
+			"Normal",
+			"policy: managed/case8-test-policy-trustedcontainerpolicy",
+			"Compliant; No violation detected")
+		Eventually(checkCompliance(case8PolicyName), defaultTimeoutSeconds, 1).Should(Equal("Compliant"))
+		By("Checking the conditions on the managed policy")
+		Eventually(getCondition(case8PolicyName, sync.ConditionHubReachable), defaultTimeoutSeconds, 1).
+			Should(Equal([]string{"True", "HubRequestSucceeded"}))
+		Eventually(getCondition(case8PolicyName, sync.ConditionSynced), defaultTimeoutSeconds, 1).
+			Should(Equal([]string{"True", "HubStatusUpdated"}))
+	})
+	It("Should set the SpecInSync condition after the managed policy is reverted", func() {
+		By("Patching the managed policy with spec.remediationAction = enforce")
+		Eventually(func() interface{} {
+			managedPlc := utils.GetWithTimeout(
+				clientManagedDynamic, gvrPolicy, case8PolicyName, testNamespace, true, defaultTimeoutSeconds,
+			)
+			managedPlc.Object["spec"].(map[string]interface{})["remediationAction"] = "enforce"
+			_, err := clientManagedDynamic.Resource(gvrPolicy).Namespace(testNamespace).Update(
+				context.TODO(), managedPlc, metav1.UpdateOptions{},
+			)
+
+			return err
+		}, defaultTimeoutSeconds, 1).Should(BeNil())
+		By("Checking the SpecInSync condition on the managed policy")
+		Eventually(getCondition(case8PolicyName, sync.ConditionSpecInSync), defaultTimeoutSeconds, 1).
+			Should(Equal([]string{"True", "Reverted"}))
+	})
+})
@@ -0,0 +1,104 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package e2e
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"open-cluster-management.io/governance-policy-propagator/test/utils"
+
+	"open-cluster-management.io/governance-policy-status-sync/controllers/sync"
+)
+
+const (
+	case9PolicyName string = "default.case9-test-policy"
+	case9PolicyYaml string = "../resources/case9_disabled/case9-test-policy.yaml"
+)
+
+// setDisabled sets spec.disabled on the hub policy, which is then synced to the managed policy
+func setDisabled(name string, disabled bool) {
+	patch := `{"spec":{"disabled":false}}`
+	if disabled {
+		patch = `{"spec":{"disabled":true}}`
+	}
+
+	_, err := utils.KubectlWithOutput("patch", "policies.policy.open-cluster-management.io", name,
+		"-n", clusterNamespaceOnHub, "--type=merge", "-p", patch, "--kubeconfig=../../kubeconfig_hub")
+	Expect(err).ShouldNot(HaveOccurred())
+}
+
+var _ = Describe("Test the status sync of a disabled policy", func() {
+	BeforeEach(func() {
+		By("Creating a policy on hub cluster in ns:" + clusterNamespaceOnHub)
+		_, err := utils.KubectlWithOutput("apply", "-f", case9PolicyYaml, "-n", clusterNamespaceOnHub,
+			"--kubeconfig=../../kubeconfig_hub")
+		Expect(err).ShouldNot(HaveOccurred())
+		hubPlc := utils.GetWithTimeout(
+			clientHubDynamic, gvrPolicy, case9PolicyName, clusterNamespaceOnHub, true, defaultTimeoutSeconds,
+		)
+		Expect(hubPlc).NotTo(BeNil())
+		By("Creating a policy on managed cluster in ns:" + testNamespace)
+		_, err = utils.KubectlWithOutput("apply", "-f", case9PolicyYaml, "-n", testNamespace,
+			"--kubeconfig=../../kubeconfig_managed")
+		Expect(err).ShouldNot(HaveOccurred())
+		managedPlc := utils.GetWithTimeout(
+			clientManagedDynamic, gvrPolicy, case9PolicyName, testNamespace, true, defaultTimeoutSeconds,
+		)
+		Expect(managedPlc).NotTo(BeNil())
+	})
+	AfterEach(func() {
+		By("Deleting a policy on hub cluster in ns:" + clusterNamespaceOnHub)
+		_, err := utils.KubectlWithOutput("delete", "-f", case9PolicyYaml, "-n", clusterNamespaceOnHub,
+			"--kubeconfig=../../kubeconfig_hub")
+		Expect(err).ShouldNot(HaveOccurred())
+		_, err = utils.KubectlWithOutput("delete", "-f", case9PolicyYaml, "-n", testNamespace,
+			"--ignore-not-found", "--kubeconfig=../../kubeconfig_managed")
+		Expect(err).ShouldNot(HaveOccurred())
+		opt := metav1.ListOptions{}
+		utils.ListWithTimeout(clientHubDynamic, gvrPolicy, opt, 0, true, defaultTimeoutSeconds)
+		utils.ListWithTimeout(clientManagedDynamic, gvrPolicy, opt, 0, true, defaultTimeoutSeconds)
+		By("clean up all events")
+		_, err = utils.KubectlWithOutput("delete", "events", "-n", testNamespace, "--all",
+			"--kubeconfig=../../kubeconfig_managed")
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+	It("Should clear the status while the policy is disabled and merge new events once enabled", func() {
+		By("Generating a noncompliant event on the managed policy")
+		managedPlc := utils.GetWithTimeout(
+			clientManagedDynamic, gvrPolicy, case9PolicyName, testNamespace, true, defaultTimeoutSeconds,
+		)
+		managedRecorder.Event(
+			managedPlc,
+			"Warning",
+			"policy: managed/case9-test-policy-trustedcontainerpolicy",
+			"NonCompliant; Violation detected")
+		Eventually(checkCompliance(case9PolicyName), defaultTimeoutSeconds, 1).Should(Equal("NonCompliant"))
+		By("Disabling the hub policy")
+		setDisabled(case9PolicyName, true)
+		Eventually(getCondition(case9PolicyName, sync.ConditionDisabled), defaultTimeoutSeconds, 1).
+			Should(Equal([]string{"True", "PolicyDisabled"}))
+		Eventually(checkCompliance(case9PolicyName), defaultTimeoutSeconds, 1).
+			ShouldNot(Equal("NonCompliant"))
+		Eventually(func() interface{} {
+			hubPlc := utils.GetWithTimeout(
+				clientHubDynamic, gvrPolicy, case9PolicyName, clusterNamespaceOnHub, true, defaultTimeoutSeconds,
+			)
+
+			return hubPlc.Object["status"]
+		}, defaultTimeoutSeconds, 1).Should(Or(BeNil(), BeEmpty()))
+		By("Enabling the hub policy and generating a compliant event")
+		setDisabled(case9PolicyName, false)
+		Eventually(getCondition(case9PolicyName, sync.ConditionDisabled), defaultTimeoutSeconds, 1).
+			Should(Equal([]string{"False", "PolicyEnabled"}))
+		managedPlc = utils.GetWithTimeout(
+			clientManagedDynamic, gvrPolicy, case9PolicyName, testNamespace, true, defaultTimeoutSeconds,
+		)
+		managedRecorder.Event(
+			managedPlc,
+			"Normal",
+			"policy: managed/case9-test-policy-trustedcontainerpolicy",
+			"Compliant; No violation detected")
+		Eventually(checkCompliance(case9PolicyName), defaultTimeoutSeconds, 1).Should(Equal("Compliant"))
+	})
+})
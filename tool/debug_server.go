@@ -0,0 +1,56 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package tool
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"open-cluster-management.io/governance-policy-status-sync/controllers/sync"
+)
+
+// DebugServer serves a debug handler, such as the one returned by PolicyReconciler.DebugHandler, until the manager
+// stops. It is added to the manager as a Runnable.
+type DebugServer struct {
+	Addr    string
+	Handler http.Handler
+}
+
+// Start serves the debug handler until the input context is done.
+func (s *DebugServer) Start(ctx context.Context) error {
+	log.Info("Serving the debug endpoint", "address", s.Addr, "path", sync.DebugPoliciesPath)
+
+	return serveUntilDone(ctx, &http.Server{Addr: s.Addr, Handler: s.Handler, ReadHeaderTimeout: 10 * time.Second})
+}
+
+// NeedLeaderElection returns false so every replica serves its debug endpoint. Only the leader reconciles, so the
+// other replicas serve an empty list.
+func (s *DebugServer) NeedLeaderElection() bool {
+	return false
+}
+
+// serveUntilDone serves the input HTTP server until it fails or the input context is done, in which case the server
+// is shut down.
+func serveUntilDone(ctx context.Context, server *http.Server) error {
+	serveErr := make(chan error, 1)
+
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+
+		return nil
+	}
+}
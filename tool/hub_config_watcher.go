@@ -0,0 +1,170 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package tool
+
+import (
+	"context"
+	"crypto/sha256"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// HubConfigWatcher polls the hub kubeconfig file and calls OnChange with a new REST config whenever the content of
+// the file changes, for example when the addon framework rotates the hub credentials.
+type HubConfigWatcher struct {
+	// Path is the pathname of the hub kubeconfig file
+	Path string
+	// Interval is how often the file is checked for changes
+	Interval time.Duration
+	// OnChange is called with the REST config built from the updated file. If it returns an error, the change is
+	// retried on the next check.
+	OnChange func(hubCfg *rest.Config) error
+	checksum [32]byte
+}
+
+// NewHubConfigWatcher returns a HubConfigWatcher which considers the current content of the file as already loaded.
+func NewHubConfigWatcher(
+	path string, interval time.Duration, onChange func(hubCfg *rest.Config) error,
+) (*HubConfigWatcher, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HubConfigWatcher{
+		Path:     path,
+		Interval: interval,
+		OnChange: onChange,
+		checksum: sha256.Sum256(content),
+	}, nil
+}
+
+// Start checks the hub kubeconfig file until the input context is canceled. It implements the manager.Runnable
+// interface.
+func (w *HubConfigWatcher) Start(ctx context.Context) error {
+	wait.UntilWithContext(ctx, func(_ context.Context) { w.check() }, w.Interval)
+
+	return nil
+}
+
+// NeedLeaderElection returns false so that the hub clients are also kept up to date on non-leader replicas.
+func (w *HubConfigWatcher) NeedLeaderElection() bool {
+	return false
+}
+
+func (w *HubConfigWatcher) check() {
+	content, err := os.ReadFile(w.Path)
+	if err != nil {
+		log.Error(err, "Failed to read the hub kubeconfig file", "path", w.Path)
+
+		return
+	}
+
+	checksum := sha256.Sum256(content)
+	if checksum == w.checksum {
+		return
+	}
+
+	log.Info("The hub kubeconfig file changed, reloading the hub clients", "path", w.Path)
+
+	// the relative certificate and key paths of the kubeconfig are resolved against its directory, as at startup
+	hubCfg, err := clientcmd.BuildConfigFromFlags("", w.Path)
+	if err != nil {
+		// The file may be in the middle of being written, so retry on the next check
+		log.Error(err, "Failed to build the hub cluster config, will retry", "path", w.Path)
+
+		return
+	}
+
+	ConfigureClient(hubCfg, Options.HubClientQPS, Options.HubClientBurst, Options.HubClientTimeout)
+
+	if err := w.OnChange(hubCfg); err != nil {
+		log.Error(err, "Failed to reload the hub clients, will retry", "path", w.Path)
+
+		return
+	}
+
+	w.checksum = checksum
+
+	log.Info("Reloaded the hub clients", "path", w.Path)
+}
+
+// HubTransport sends the requests with the transport of the current hub config. It lets the clients that can't be
+// rebuilt when the hub kubeconfig changes, such as the hub client of the addon lease updater, use the new server and
+// credentials.
+type HubTransport struct {
+	lock      sync.RWMutex
+	server    *url.URL
+	transport http.RoundTripper
+}
+
+// NewHubTransport returns a HubTransport which uses the input hub config.
+func NewHubTransport(hubCfg *rest.Config) (*HubTransport, error) {
+	t := &HubTransport{}
+	if err := t.Set(hubCfg); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Set replaces the hub config used by the next requests.
+func (t *HubTransport) Set(hubCfg *rest.Config) error {
+	transport, err := rest.TransportFor(hubCfg)
+	if err != nil {
+		return err
+	}
+
+	server, _, err := rest.DefaultServerURL(hubCfg.Host, "", schema.GroupVersion{}, rest.IsConfigTransportTLS(*hubCfg))
+	if err != nil {
+		return err
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.server = server
+	t.transport = transport
+
+	return nil
+}
+
+// RoundTrip sends the request to the server of the current hub config with its transport. It implements the
+// http.RoundTripper interface.
+func (t *HubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.lock.RLock()
+	server, transport := t.server, t.transport
+	t.lock.RUnlock()
+
+	if req.URL.Host != server.Host || req.URL.Scheme != server.Scheme {
+		// the server changed since the client was built
+		req = req.Clone(req.Context())
+		req.URL.Scheme = server.Scheme
+		req.URL.Host = server.Host
+		req.Host = ""
+	}
+
+	return transport.RoundTrip(req)
+}
+
+// Config returns a config, with the server and client settings of the input hub config, whose clients send their
+// requests through the HubTransport.
+func (t *HubTransport) Config(hubCfg *rest.Config) *rest.Config {
+	return &rest.Config{
+		Host:      hubCfg.Host,
+		APIPath:   hubCfg.APIPath,
+		UserAgent: hubCfg.UserAgent,
+		QPS:       hubCfg.QPS,
+		Burst:     hubCfg.Burst,
+		Timeout:   hubCfg.Timeout,
+		Transport: t,
+	}
+}
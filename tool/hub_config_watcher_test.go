@@ -0,0 +1,173 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package tool
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/client-go/rest"
+)
+
+// hubKubeconfig returns a kubeconfig to the input server with the client certificate and key of the addon hub
+// kubeconfig, relative to its directory.
+func hubKubeconfig(server string) []byte {
+	return []byte(fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: hub
+  cluster:
+    server: %s
+    insecure-skip-tls-verify: true
+contexts:
+- name: hub
+  context:
+    cluster: hub
+    user: agent
+current-context: hub
+users:
+- name: agent
+  user:
+    client-certificate: tls.crt
+    client-key: tls.key
+`, server))
+}
+
+func TestHubConfigWatcher(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "kubeconfig")
+
+	for name, content := range map[string][]byte{
+		"kubeconfig": hubKubeconfig("https://hub-1:6443"),
+		"tls.crt":    []byte("certificate"),
+		"tls.key":    []byte("key"),
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	var loaded []*rest.Config
+
+	var onChangeErr error
+
+	watcher, err := NewHubConfigWatcher(path, 0, func(hubCfg *rest.Config) error {
+		if onChangeErr != nil {
+			return onChangeErr
+		}
+
+		loaded = append(loaded, hubCfg)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to create the watcher: %v", err)
+	}
+
+	watcher.check()
+
+	if len(loaded) != 0 {
+		t.Fatal("expected the initial content not to be reloaded")
+	}
+
+	if err := os.WriteFile(path, []byte("clusters: ["), 0o600); err != nil {
+		t.Fatalf("failed to write the kubeconfig: %v", err)
+	}
+
+	watcher.check()
+
+	if len(loaded) != 0 {
+		t.Fatal("expected an invalid kubeconfig not to be loaded")
+	}
+
+	if err := os.WriteFile(path, hubKubeconfig("https://hub-2:6443"), 0o600); err != nil {
+		t.Fatalf("failed to write the kubeconfig: %v", err)
+	}
+
+	onChangeErr = errors.New("the hub clients can't be built")
+	watcher.check()
+
+	onChangeErr = nil
+	watcher.check()
+
+	if len(loaded) != 1 {
+		t.Fatalf("expected the change to be retried until it is loaded once, got %d loads", len(loaded))
+	}
+
+	if loaded[0].Host != "https://hub-2:6443" || loaded[0].CertFile != filepath.Join(dir, "tls.crt") ||
+		loaded[0].KeyFile != filepath.Join(dir, "tls.key") {
+		t.Fatalf("expected the new server and the client certificate relative to the kubeconfig, got %s, %s and %s",
+			loaded[0].Host, loaded[0].CertFile, loaded[0].KeyFile)
+	}
+
+	watcher.check()
+
+	if len(loaded) != 1 {
+		t.Fatal("expected the loaded content not to be reloaded")
+	}
+}
+
+func TestHubTransport(t *testing.T) {
+	t.Parallel()
+
+	servers := []*httptest.Server{}
+
+	for _, name := range []string{"previous", "current"} {
+		name := name
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(name))
+		}))
+		defer server.Close()
+
+		servers = append(servers, server)
+	}
+
+	transport, err := NewHubTransport(&rest.Config{Host: servers[0].URL})
+	if err != nil {
+		t.Fatalf("failed to create the transport: %v", err)
+	}
+
+	httpClient, err := rest.HTTPClientFor(transport.Config(&rest.Config{Host: servers[0].URL}))
+	if err != nil {
+		t.Fatalf("failed to create the client: %v", err)
+	}
+
+	get := func() string {
+		t.Helper()
+
+		// like the client of the lease updater, the requests keep the server of the initial config
+		resp, err := httpClient.Get(servers[0].URL) //nolint:noctx
+		if err != nil {
+			t.Fatalf("failed to send the request: %v", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("failed to read the response: %v", err)
+		}
+
+		return string(body)
+	}
+
+	if body := get(); body != "previous" {
+		t.Fatalf("expected the request to be sent to the initial server, got %q", body)
+	}
+
+	if err := transport.Set(&rest.Config{Host: servers[1].URL}); err != nil {
+		t.Fatalf("failed to set the hub config: %v", err)
+	}
+
+	if body := get(); body != "current" {
+		t.Fatalf("expected the request to be sent to the server of the new hub config, got %q", body)
+	}
+}
@@ -0,0 +1,394 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package tool
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LogLevelPath is the path of the endpoint that gets and sets the log levels
+const LogLevelPath = "/loglevel"
+
+// KlogLoggerName is the name of the logger of the klog messages. Its level also sets the klog verbosity.
+const KlogLoggerName = "klog"
+
+// LogLevels holds the zap level of each logger, by name, which can be changed at runtime. The level of a logger also
+// applies to its descendants, for example the level of "policy-status-sync" applies to "policy-status-sync.hub",
+// and the default level applies to the loggers without a level.
+type LogLevels struct {
+	lock         sync.RWMutex
+	defaultLevel zapcore.Level
+	levels       map[string]zapcore.Level
+	// initial are the default level and the levels at startup, restored by Reset
+	initialDefault zapcore.Level
+	initial        map[string]zapcore.Level
+	// enabled is the lowest of the levels. The zap cores are built with it so they enable every entry that any
+	// logger may log, and the entries are then filtered by the level of their logger.
+	enabled zap.AtomicLevel
+	// klogVerbosity is the klog "v" flag, kept in sync with the level of the klog logger
+	klogVerbosity flag.Value
+}
+
+// NewLogLevels returns LogLevels with the input default level, and the level of the klog logger set from the input
+// klog "v" flag.
+func NewLogLevels(defaultLevel zapcore.Level, klogVerbosity *flag.Flag) (*LogLevels, error) {
+	l := &LogLevels{
+		defaultLevel:   defaultLevel,
+		levels:         map[string]zapcore.Level{},
+		initialDefault: defaultLevel,
+		initial:        map[string]zapcore.Level{},
+		enabled:        zap.NewAtomicLevelAt(defaultLevel),
+	}
+
+	if klogVerbosity != nil {
+		verbosity, err := strconv.Atoi(klogVerbosity.Value.String())
+		if err != nil {
+			return nil, fmt.Errorf("invalid klog verbosity %q: %w", klogVerbosity.Value.String(), err)
+		}
+
+		l.klogVerbosity = klogVerbosity.Value
+		l.levels[KlogLoggerName] = zapcore.Level(int8(-1 * verbosity))
+		l.initial[KlogLoggerName] = l.levels[KlogLoggerName]
+	}
+
+	l.updateEnabled()
+
+	return l, nil
+}
+
+// Build builds a zap logger from the input configuration whose entries are filtered by the level of their logger.
+func (l *LogLevels) Build(cfg zap.Config) (*zap.Logger, error) {
+	cfg.Level = l.enabled
+
+	return cfg.Build(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &namedLevelCore{Core: core, levels: l}
+	}))
+}
+
+// Enabled returns whether an entry at the input level of the logger with the input name is logged.
+func (l *LogLevels) Enabled(name string, level zapcore.Level) bool {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	for {
+		if loggerLevel, found := l.levels[name]; found {
+			return loggerLevel.Enabled(level)
+		}
+
+		index := strings.LastIndex(name, ".")
+		if index < 0 {
+			return l.defaultLevel.Enabled(level)
+		}
+
+		name = name[:index]
+	}
+}
+
+// SetLevel sets the level of the logger with the input name, or the default level if the name is empty.
+func (l *LogLevels) SetLevel(name string, level zapcore.Level) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if name == "" {
+		l.defaultLevel = level
+	} else {
+		l.levels[name] = level
+	}
+
+	return l.update()
+}
+
+// UnsetLevel removes the level of the logger with the input name, which then uses the level of its parent or the
+// default level.
+func (l *LogLevels) UnsetLevel(name string) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	delete(l.levels, name)
+
+	return l.update()
+}
+
+// IncreaseVerbosity lowers the default level and the level of every logger by one, down to the verbosity 10.
+func (l *LogLevels) IncreaseVerbosity() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	const lowest = zapcore.Level(-10)
+
+	if l.defaultLevel > lowest {
+		l.defaultLevel--
+	}
+
+	for name, level := range l.levels {
+		if level > lowest {
+			l.levels[name] = level - 1
+		}
+	}
+
+	return l.update()
+}
+
+// Reset restores the default level and the levels at startup.
+func (l *LogLevels) Reset() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.defaultLevel = l.initialDefault
+	l.levels = map[string]zapcore.Level{}
+
+	for name, level := range l.initial {
+		l.levels[name] = level
+	}
+
+	return l.update()
+}
+
+// Levels returns the default level and the level of each logger, formatted as by FormatLevel.
+func (l *LogLevels) Levels() (string, map[string]string) {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	levels := map[string]string{}
+	for name, level := range l.levels {
+		levels[name] = FormatLevel(level)
+	}
+
+	return FormatLevel(l.defaultLevel), levels
+}
+
+// update updates the enabled level and the klog verbosity after a level changed. The lock must be held.
+func (l *LogLevels) update() error {
+	l.updateEnabled()
+
+	if l.klogVerbosity == nil {
+		return nil
+	}
+
+	klogLevel := l.defaultLevel
+	if level, found := l.levels[KlogLoggerName]; found {
+		klogLevel = level
+	}
+
+	verbosity := 0
+	if klogLevel < zapcore.InfoLevel {
+		verbosity = -1 * int(klogLevel)
+	}
+
+	return l.klogVerbosity.Set(strconv.Itoa(verbosity))
+}
+
+// updateEnabled sets the enabled level to the lowest of the levels. The lock must be held.
+func (l *LogLevels) updateEnabled() {
+	lowest := l.defaultLevel
+
+	for _, level := range l.levels {
+		if level < lowest {
+			lowest = level
+		}
+	}
+
+	l.enabled.SetLevel(lowest)
+}
+
+// namedLevelCore is a zap core that filters the entries by the level of their logger.
+type namedLevelCore struct {
+	zapcore.Core
+	levels *LogLevels
+}
+
+func (c *namedLevelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &namedLevelCore{Core: c.Core.With(fields), levels: c.levels}
+}
+
+func (c *namedLevelCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.levels.Enabled(entry.LoggerName, entry.Level) {
+		return checked
+	}
+
+	return c.Core.Check(entry, checked)
+}
+
+// ParseLevel parses a zap level name, such as "info" or "debug", or a verbosity, such as "2", like the --log-level
+// flag.
+func ParseLevel(value string) (zapcore.Level, error) {
+	level, err := zapcore.ParseLevel(strings.ToLower(value))
+	if err == nil {
+		return level, nil
+	}
+
+	verbosity, err := strconv.Atoi(value)
+	if err != nil || verbosity < 0 || verbosity > 127 {
+		return 0, fmt.Errorf("invalid log level %q", value)
+	}
+
+	return zapcore.Level(int8(-1 * verbosity)), nil
+}
+
+// FormatLevel formats a zap level as its name, or as a verbosity for the levels below debug, so it can be parsed by
+// ParseLevel.
+func FormatLevel(level zapcore.Level) string {
+	if level >= zapcore.DebugLevel {
+		return level.String()
+	}
+
+	return strconv.Itoa(-1 * int(level))
+}
+
+// logLevelsResponse is the response of the log level endpoint.
+type logLevelsResponse struct {
+	Default string            `json:"default"`
+	Loggers map[string]string `json:"loggers"`
+}
+
+// LogLevelServer changes the log levels at runtime. It serves the log level endpoint, authenticated with a bearer
+// token, and, if enabled, increases the verbosity of every logger on SIGUSR1 and restores the levels at startup on
+// SIGUSR2. It is added to the manager as a Runnable.
+type LogLevelServer struct {
+	Levels *LogLevels
+	// Addr is the address the log level endpoint binds to. The endpoint is disabled when empty.
+	Addr string
+	// Token is the bearer token required by the log level endpoint
+	Token string
+	// Signals enables the SIGUSR1 and SIGUSR2 handlers
+	Signals bool
+}
+
+// Start serves the log level endpoint and handles the signals until the input context is done.
+func (s *LogLevelServer) Start(ctx context.Context) error {
+	if s.Signals {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+
+		defer signal.Stop(signals)
+
+		go s.handleSignals(ctx, signals)
+	}
+
+	if s.Addr == "" {
+		<-ctx.Done()
+
+		return nil
+	}
+
+	if s.Token == "" {
+		return errors.New("the log level endpoint requires a token")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(LogLevelPath, s.serveLogLevels)
+
+	log.Info("Serving the log level endpoint", "address", s.Addr, "path", LogLevelPath)
+
+	return serveUntilDone(ctx, &http.Server{Addr: s.Addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second})
+}
+
+// NeedLeaderElection returns false so the log levels can be changed on every replica.
+func (s *LogLevelServer) NeedLeaderElection() bool {
+	return false
+}
+
+func (s *LogLevelServer) handleSignals(ctx context.Context, signals <-chan os.Signal) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-signals:
+			var err error
+
+			if sig == syscall.SIGUSR1 {
+				err = s.Levels.IncreaseVerbosity()
+			} else {
+				err = s.Levels.Reset()
+			}
+
+			defaultLevel, levels := s.Levels.Levels()
+			if err != nil {
+				log.Error(err, "Failed to change the log levels", "signal", sig.String())
+			} else {
+				log.Info("Changed the log levels", "signal", sig.String(), "default", defaultLevel, "loggers", levels)
+			}
+		}
+	}
+}
+
+// serveLogLevels returns the log levels on GET, sets the level of the logger in the "logger" query parameter, or the
+// default level if it is omitted, to the "level" query parameter on PUT, and removes the level of the logger, or
+// restores the levels at startup if it is omitted, on DELETE.
+func (s *LogLevelServer) serveLogLevels(w http.ResponseWriter, req *http.Request) {
+	token, bearer := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !bearer || subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+
+		return
+	}
+
+	name := req.URL.Query().Get("logger")
+
+	var err error
+
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var level zapcore.Level
+
+		// zap parses an empty level as info
+		if req.URL.Query().Get("level") == "" {
+			http.Error(w, "the level query parameter is required", http.StatusBadRequest)
+
+			return
+		}
+
+		level, err = ParseLevel(req.URL.Query().Get("level"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		err = s.Levels.SetLevel(name, level)
+	case http.MethodDelete:
+		if name == "" {
+			err = s.Levels.Reset()
+		} else {
+			err = s.Levels.UnsetLevel(name)
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	defaultLevel, levels := s.Levels.Levels()
+	if req.Method != http.MethodGet {
+		log.Info("Changed the log levels", "default", defaultLevel, "loggers", levels)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(logLevelsResponse{Default: defaultLevel, Loggers: levels}); err != nil {
+		log.V(1).Info("Failed to write the log level response", "error", err.Error())
+	}
+}
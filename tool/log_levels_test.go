@@ -0,0 +1,223 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package tool
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLogLevelsEnabled(t *testing.T) {
+	t.Parallel()
+
+	levels, err := NewLogLevels(zapcore.InfoLevel, nil)
+	if err != nil {
+		t.Fatalf("failed to build the log levels: %v", err)
+	}
+
+	if err := levels.SetLevel("policy-status-sync", zapcore.Level(-2)); err != nil {
+		t.Fatalf("failed to set the level: %v", err)
+	}
+
+	if err := levels.SetLevel("policy-status-sync.hub", zapcore.ErrorLevel); err != nil {
+		t.Fatalf("failed to set the level: %v", err)
+	}
+
+	tests := map[string]struct {
+		name     string
+		level    zapcore.Level
+		expected bool
+	}{
+		"default level":             {"controller-runtime", zapcore.InfoLevel, true},
+		"below the default level":   {"controller-runtime", zapcore.DebugLevel, false},
+		"logger level":              {"policy-status-sync", zapcore.Level(-2), true},
+		"below the logger level":    {"policy-status-sync", zapcore.Level(-3), false},
+		"inherited level":           {"policy-status-sync.managed", zapcore.Level(-2), true},
+		"inherited by a grandchild": {"policy-status-sync.managed.events", zapcore.Level(-2), true},
+		"child level":               {"policy-status-sync.hub", zapcore.InfoLevel, false},
+		"inherited child level":     {"policy-status-sync.hub.status", zapcore.ErrorLevel, true},
+		"name prefix only":          {"policy-status-sync-other", zapcore.DebugLevel, false},
+		"unnamed logger":            {"", zapcore.InfoLevel, true},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if enabled := levels.Enabled(test.name, test.level); enabled != test.expected {
+				t.Fatalf("expected the level %s of %q to be enabled: %v, got %v", test.level, test.name,
+					test.expected, enabled)
+			}
+		})
+	}
+}
+
+func TestLogLevelsKlogVerbosity(t *testing.T) {
+	t.Parallel()
+
+	// a flag set of its own instead of the global klog flags
+	flags := flag.NewFlagSet("klog", flag.ContinueOnError)
+	flags.Int("v", 2, "")
+
+	levels, err := NewLogLevels(zapcore.InfoLevel, flags.Lookup("v"))
+	if err != nil {
+		t.Fatalf("failed to build the log levels: %v", err)
+	}
+
+	steps := []struct {
+		description    string
+		change         func() error
+		defaultLevel   string
+		klogLevel      string
+		klogVerbosity  string
+		enabledAtDebug bool
+	}{
+		{"startup", func() error { return nil }, "info", "2", "2", true},
+		{"set the klog level", func() error { return levels.SetLevel(KlogLoggerName, zapcore.WarnLevel) },
+			"info", "warn", "0", false},
+		{"increase the verbosity", levels.IncreaseVerbosity, "debug", "info", "0", true},
+		{"increase the verbosity again", levels.IncreaseVerbosity, "2", "debug", "1", true},
+		{"unset the klog level", func() error { return levels.UnsetLevel(KlogLoggerName) }, "2", "", "2", true},
+		{"set the default level", func() error { return levels.SetLevel("", zapcore.ErrorLevel) },
+			"error", "", "0", false},
+		{"reset", levels.Reset, "info", "2", "2", true},
+	}
+
+	for _, step := range steps {
+		if err := step.change(); err != nil {
+			t.Fatalf("%s: failed to change the levels: %v", step.description, err)
+		}
+
+		defaultLevel, loggerLevels := levels.Levels()
+		if defaultLevel != step.defaultLevel || loggerLevels[KlogLoggerName] != step.klogLevel {
+			t.Fatalf("%s: expected the default level %s and the klog level %q, got %s and %v", step.description,
+				step.defaultLevel, step.klogLevel, defaultLevel, loggerLevels)
+		}
+
+		if verbosity := flags.Lookup("v").Value.String(); verbosity != step.klogVerbosity {
+			t.Fatalf("%s: expected the klog verbosity %s, got %s", step.description, step.klogVerbosity, verbosity)
+		}
+
+		// the zap cores must enable every level that any logger logs
+		if enabled := levels.enabled.Enabled(zapcore.DebugLevel); enabled != step.enabledAtDebug {
+			t.Fatalf("%s: expected the debug level to be enabled: %v, got %v", step.description,
+				step.enabledAtDebug, enabled)
+		}
+	}
+}
+
+func TestLogLevelServer(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		method        string
+		query         string
+		authorization string
+		code          int
+		expected      logLevelsResponse
+	}{
+		"get": {
+			method: http.MethodGet, authorization: "Bearer token", code: http.StatusOK,
+			expected: logLevelsResponse{Default: "info", Loggers: map[string]string{"policy-status-sync": "debug"}},
+		},
+		"no token": {method: http.MethodGet, code: http.StatusUnauthorized},
+		"wrong token": {
+			method: http.MethodGet, authorization: "Bearer other", code: http.StatusUnauthorized,
+		},
+		"token without the bearer scheme": {
+			method: http.MethodGet, authorization: "token", code: http.StatusUnauthorized,
+		},
+		"other scheme": {
+			method: http.MethodGet, authorization: "Basic token", code: http.StatusUnauthorized,
+		},
+		"set a logger level": {
+			method: http.MethodPut, query: "logger=policy-status-sync.hub&level=2", authorization: "Bearer token",
+			code: http.StatusOK,
+			expected: logLevelsResponse{Default: "info", Loggers: map[string]string{
+				"policy-status-sync": "debug", "policy-status-sync.hub": "2",
+			}},
+		},
+		"set the default level": {
+			method: http.MethodPut, query: "level=error", authorization: "Bearer token", code: http.StatusOK,
+			expected: logLevelsResponse{Default: "error", Loggers: map[string]string{"policy-status-sync": "debug"}},
+		},
+		"invalid level": {
+			method: http.MethodPut, query: "logger=policy-status-sync&level=verbose", authorization: "Bearer token",
+			code: http.StatusBadRequest,
+		},
+		"missing level": {
+			method: http.MethodPut, query: "logger=policy-status-sync", authorization: "Bearer token",
+			code: http.StatusBadRequest,
+		},
+		"unset a logger level": {
+			method: http.MethodDelete, query: "logger=policy-status-sync", authorization: "Bearer token",
+			code: http.StatusOK, expected: logLevelsResponse{Default: "info", Loggers: map[string]string{}},
+		},
+		"reset": {
+			method: http.MethodDelete, authorization: "Bearer token", code: http.StatusOK,
+			expected: logLevelsResponse{Default: "info", Loggers: map[string]string{}},
+		},
+		"unsupported method": {
+			method: http.MethodPost, authorization: "Bearer token", code: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			levels, err := NewLogLevels(zapcore.InfoLevel, nil)
+			if err != nil {
+				t.Fatalf("failed to build the log levels: %v", err)
+			}
+
+			if err := levels.SetLevel("policy-status-sync", zapcore.DebugLevel); err != nil {
+				t.Fatalf("failed to set the level: %v", err)
+			}
+
+			server := &LogLevelServer{Levels: levels, Token: "token"}
+
+			req := httptest.NewRequest(test.method, LogLevelPath+"?"+test.query, nil)
+			if test.authorization != "" {
+				req.Header.Set("Authorization", test.authorization)
+			}
+
+			recorder := httptest.NewRecorder()
+			server.serveLogLevels(recorder, req)
+
+			if recorder.Code != test.code {
+				t.Fatalf("expected the status code %d, got %d: %s", test.code, recorder.Code, recorder.Body.String())
+			}
+
+			if test.code != http.StatusOK {
+				defaultLevel, loggerLevels := levels.Levels()
+				if defaultLevel != "info" || !reflect.DeepEqual(loggerLevels, map[string]string{
+					"policy-status-sync": "debug",
+				}) {
+					t.Fatalf("expected the levels to be unchanged, got %s and %v", defaultLevel, loggerLevels)
+				}
+
+				return
+			}
+
+			response := logLevelsResponse{}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("failed to parse the response %q: %v", recorder.Body.String(), err)
+			}
+
+			if !reflect.DeepEqual(response, test.expected) {
+				t.Fatalf("expected the response %+v, got %+v", test.expected, response)
+			}
+		})
+	}
+}
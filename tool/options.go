@@ -4,12 +4,27 @@
 package tool
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/spf13/pflag"
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
+
+	"open-cluster-management.io/governance-policy-status-sync/controllers/sync"
 )
 
 var log = ctrl.Log.WithName("cmd")
 
+// Values of the --preflight flag
+const (
+	PreflightNone = "none"
+	PreflightWarn = "warn"
+	PreflightFail = "fail"
+)
+
 // PolicySpecSyncOptions for command line flag parsing
 type PolicySpecSyncOptions struct {
 	ClusterNamespaceOnHub     string
@@ -19,6 +34,61 @@ type PolicySpecSyncOptions struct {
 	EnableLeaderElection      bool
 	LegacyLeaderElection      bool
 	ProbeAddr                 string
+	HubClientQPS              float32
+	HubClientBurst            int
+	HubClientTimeout          time.Duration
+	ManagedClientQPS          float32
+	ManagedClientBurst        int
+	ManagedClientTimeout      time.Duration
+	MaxConcurrentReconciles   int
+	RateLimiterBaseDelay      time.Duration
+	RateLimiterMaxDelay       time.Duration
+	RateLimiterQPS            int
+	RateLimiterBurst          int
+	ResyncPeriod              time.Duration
+	ReloadHubConfig           bool
+	ReadinessTimeout          time.Duration
+	Preflight                 string
+	DeletionGracePeriod       time.Duration
+	MassDeletionThreshold     float64
+	StatusOnly                bool
+	MetricsAddr               string
+	HubOwnedLabels            []string
+	ManagedLocalLabels        []string
+	HubOwnedAnnotations       []string
+	ManagedLocalAnnotations   []string
+	PreserveMissingMetadata   bool
+	RecoveryFightThreshold    int
+	RecoveryFightWindow       time.Duration
+	RecoveryBackoffMax        time.Duration
+	StaleThreshold            time.Duration
+	StaleThresholdPerKind     map[string]string
+	FlapThreshold             int
+	FlapWindow                time.Duration
+	FlapDampingPeriod         time.Duration
+	ExportPolicyReports       bool
+	NotificationEndpoints     []string
+	NotificationSource        string
+	NotificationQueueSize     int
+	NotificationMaxRetries    int
+	NotificationRetryDelay    time.Duration
+	NotificationTimeout       time.Duration
+	NotificationCAFile        string
+	NotificationCertFile      string
+	NotificationKeyFile       string
+	NotificationInsecure      bool
+	HistoryLimit              int
+	AuditLogPath              string
+	AuditLogMaxSizeMB         int
+	AuditLogMaxBackups        int
+	DebugAddr                 string
+	LogLevelAddr              string
+	LogLevelTokenFile         string
+	LogLevelSignals           bool
+	MessageRedactionRules     string
+	MessageMaxLength          int
+	KeepFullManagedMessages   bool
+	StatusSizeBudget          int
 }
 
 // Options default value
@@ -77,4 +147,475 @@ func ProcessFlags() {
 		":8082",
 		"The address the probe endpoint binds to.",
 	)
+
+	flag.Float32Var(
+		&Options.HubClientQPS,
+		"hub-client-qps",
+		0,
+		"The maximum queries per second of the clients to the hub cluster, including the event broadcaster. "+
+			"Zero uses the client-go default.",
+	)
+
+	flag.IntVar(
+		&Options.HubClientBurst,
+		"hub-client-burst",
+		0,
+		"The maximum burst of the clients to the hub cluster, including the event broadcaster. "+
+			"Zero uses the client-go default.",
+	)
+
+	flag.DurationVar(
+		&Options.HubClientTimeout,
+		"hub-client-timeout",
+		0,
+		"The timeout of a single request to the hub cluster (e.g. 30s). Zero means no timeout.",
+	)
+
+	flag.Float32Var(
+		&Options.ManagedClientQPS,
+		"managed-client-qps",
+		0,
+		"The maximum queries per second of the clients to the managed cluster. "+
+			"Zero uses the controller-runtime default.",
+	)
+
+	flag.IntVar(
+		&Options.ManagedClientBurst,
+		"managed-client-burst",
+		0,
+		"The maximum burst of the clients to the managed cluster. Zero uses the controller-runtime default.",
+	)
+
+	flag.DurationVar(
+		&Options.ManagedClientTimeout,
+		"managed-client-timeout",
+		0,
+		"The timeout of a single request to the managed cluster (e.g. 30s). Zero means no timeout. It doesn't apply "+
+			"to the watches of the cache, which are long-running requests.",
+	)
+
+	flag.IntVar(
+		&Options.MaxConcurrentReconciles,
+		"max-concurrent-reconciles",
+		sync.DefaultMaxConcurrentReconciles,
+		"The maximum number of policies that are reconciled in parallel.",
+	)
+
+	flag.DurationVar(
+		&Options.RateLimiterBaseDelay,
+		"rate-limiter-base-delay",
+		5*time.Millisecond,
+		"The initial delay before a failed reconcile of a policy is retried. The delay doubles on every failure.",
+	)
+
+	flag.DurationVar(
+		&Options.RateLimiterMaxDelay,
+		"rate-limiter-max-delay",
+		1000*time.Second,
+		"The maximum delay before a failed reconcile of a policy is retried.",
+	)
+
+	flag.IntVar(
+		&Options.RateLimiterQPS,
+		"rate-limiter-qps",
+		10,
+		"The overall number of requeued reconcile requests allowed per second.",
+	)
+
+	flag.IntVar(
+		&Options.RateLimiterBurst,
+		"rate-limiter-burst",
+		100,
+		"The overall burst of requeued reconcile requests.",
+	)
+
+	flag.DurationVar(
+		&Options.ResyncPeriod,
+		"resync-period",
+		0,
+		"The interval at which every policy is reconciled again even without events (e.g. 10m). "+
+			"A jitter of up to 10% is added. Zero disables the periodic resync.",
+	)
+
+	flag.BoolVar(
+		&Options.ReloadHubConfig,
+		"reload-hub-config",
+		false,
+		"If enabled, the hub clients are rebuilt in place when the hub kubeconfig file changes instead of the "+
+			"health check failing and the pod being restarted.",
+	)
+
+	flag.DurationVar(
+		&Options.ReadinessTimeout,
+		"readiness-timeout",
+		5*time.Second,
+		"The timeout of each readiness check, such as the hub API server being reachable.",
+	)
+
+	flag.StringVar(
+		&Options.Preflight,
+		"preflight",
+		PreflightNone,
+		"Verify the RBAC permissions on the hub and managed clusters at startup. "+
+			"Use \"none\" to skip the verification, \"warn\" to report missing permissions, "+
+			"or \"fail\" to also exit when a permission is missing.",
+	)
+
+	flag.DurationVar(
+		&Options.DeletionGracePeriod,
+		"deletion-grace-period",
+		0,
+		"How long a policy must be missing on the hub before it is deleted on the managed cluster (e.g. 5m).",
+	)
+
+	flag.Float64Var(
+		&Options.MassDeletionThreshold,
+		"mass-deletion-threshold",
+		0,
+		"The share of the policies, between 0 and 1, that may be missing on the hub at the same time before "+
+			"deletions on the managed cluster are held (e.g. 0.5). Held policies get a PolicyDeletionHeld event. "+
+			"Zero disables this check.",
+	)
+
+	flag.BoolVar(
+		&Options.StatusOnly,
+		"status-only",
+		false,
+		"If enabled, only the policy statuses are synced. Managed policies are never recreated, reverted or "+
+			"deleted, and a spec drift is reported with a PolicySpecDrift event, the policy_status_sync_spec_drift "+
+			"metric and the SpecInSync condition.",
+	)
+
+	flag.StringVar(
+		&Options.MetricsAddr,
+		"metrics-bind-address",
+		"0",
+		"The address the metrics endpoint binds to. Use \"0\" to disable the metrics endpoint.",
+	)
+
+	flag.StringSliceVar(
+		&Options.HubOwnedLabels,
+		"hub-owned-labels",
+		nil,
+		"The label keys synced from the hub policy to the managed policy. A key ending with \"*\" is a prefix. "+
+			"All the labels are synced by default.",
+	)
+
+	flag.StringSliceVar(
+		&Options.ManagedLocalLabels,
+		"managed-local-labels",
+		nil,
+		"The label keys preserved on the managed policy instead of being synced from the hub policy. "+
+			"A key ending with \"*\" is a prefix.",
+	)
+
+	flag.StringSliceVar(
+		&Options.HubOwnedAnnotations,
+		"hub-owned-annotations",
+		nil,
+		"The annotation keys synced from the hub policy to the managed policy. A key ending with \"*\" is a "+
+			"prefix. All the annotations are synced by default.",
+	)
+
+	flag.StringSliceVar(
+		&Options.ManagedLocalAnnotations,
+		"managed-local-annotations",
+		nil,
+		"The annotation keys preserved on the managed policy instead of being synced from the hub policy. "+
+			"A key ending with \"*\" is a prefix.",
+	)
+
+	flag.BoolVar(
+		&Options.PreserveMissingMetadata,
+		"preserve-missing-hub-owned-metadata",
+		false,
+		"If enabled, the hub-owned labels and annotations of the managed policy that the hub policy doesn't have "+
+			"are preserved when the managed policy is reverted. They are removed by default, so list the keys "+
+			"added on the managed cluster in --managed-local-labels and --managed-local-annotations.",
+	)
+
+	flag.IntVar(
+		&Options.RecoveryFightThreshold,
+		"recovery-fight-threshold",
+		sync.DefaultRecoveryFightThreshold,
+		"The number of times a managed policy may be recreated, or reverted after a change on the managed cluster, "+
+			"within the recovery fight window before the controller backs off from recovering it. The updates from "+
+			"the hub aren't counted. Zero disables the backoff, which is the default.",
+	)
+
+	flag.DurationVar(
+		&Options.RecoveryFightWindow,
+		"recovery-fight-window",
+		sync.DefaultRecoveryFightWindow,
+		"The window in which the recoveries of a managed policy are counted. It is also the initial backoff delay, "+
+			"which doubles on every consecutive backoff.",
+	)
+
+	flag.DurationVar(
+		&Options.RecoveryBackoffMax,
+		"recovery-backoff-max",
+		sync.DefaultRecoveryBackoffMax,
+		"The maximum delay before a managed policy that another actor repeatedly changes is recovered again.",
+	)
+
+	flag.DurationVar(
+		&Options.StaleThreshold,
+		"stale-threshold",
+		0,
+		"The age of the newest event of a policy template after which its compliance state is reported as "+
+			"Unknown. Zero disables the staleness detection.",
+	)
+
+	flag.StringToStringVar(
+		&Options.StaleThresholdPerKind,
+		"stale-threshold-per-kind",
+		nil,
+		"The staleness threshold of the policy templates of a kind, such as ConfigurationPolicy=1h. It takes "+
+			"precedence over --stale-threshold.",
+	)
+
+	flag.IntVar(
+		&Options.FlapThreshold,
+		"flap-threshold",
+		0,
+		"The number of compliance state changes of a policy template within the flap window after which the "+
+			"template is reported as flapping. It must be lower than --history-limit since the changes are counted "+
+			"in the kept history. Zero disables the detection.",
+	)
+
+	flag.DurationVar(
+		&Options.FlapWindow,
+		"flap-window",
+		sync.DefaultFlapWindow,
+		"The window in which the compliance state changes of a policy template are counted. Zero counts the "+
+			"whole history.",
+	)
+
+	flag.DurationVar(
+		&Options.FlapDampingPeriod,
+		"flap-damping-period",
+		0,
+		"The minimum interval between hub status updates that are only caused by flapping policy templates. "+
+			"Zero disables the damping.",
+	)
+
+	flag.BoolVar(
+		&Options.ExportPolicyReports,
+		"export-policy-reports",
+		false,
+		"Export the status of every managed policy as a wgpolicyk8s.io PolicyReport in the same namespace. The "+
+			"PolicyReport CRD must be installed on the managed cluster.",
+	)
+
+	flag.StringSliceVar(
+		&Options.NotificationEndpoints,
+		"notification-endpoints",
+		nil,
+		"The URLs to which every compliance state change of a policy or policy template is posted as a CloudEvent.",
+	)
+
+	flag.StringVar(
+		&Options.NotificationSource,
+		"notification-source",
+		"",
+		"The CloudEvents source of the compliance state change notifications. It defaults to the cluster "+
+			"namespace on the hub.",
+	)
+
+	flag.IntVar(
+		&Options.NotificationQueueSize,
+		"notification-queue-size",
+		1000,
+		"The number of compliance state change notifications waiting to be sent after which new ones are dropped.",
+	)
+
+	flag.IntVar(
+		&Options.NotificationMaxRetries,
+		"notification-max-retries",
+		5,
+		"The number of times a failed notification is retried.",
+	)
+
+	flag.DurationVar(
+		&Options.NotificationRetryDelay,
+		"notification-retry-delay",
+		time.Second,
+		"The delay before the first retry of a failed notification, which doubles on every retry.",
+	)
+
+	flag.DurationVar(
+		&Options.NotificationTimeout,
+		"notification-timeout",
+		10*time.Second,
+		"The timeout of each notification request.",
+	)
+
+	flag.StringVar(
+		&Options.NotificationCAFile,
+		"notification-ca-file",
+		"",
+		"The PEM file of the certificate authorities trusted for the notification endpoints, in addition to the "+
+			"system ones.",
+	)
+
+	flag.StringVar(
+		&Options.NotificationCertFile,
+		"notification-client-cert-file",
+		"",
+		"The PEM file of the client certificate presented to the notification endpoints.",
+	)
+
+	flag.StringVar(
+		&Options.NotificationKeyFile,
+		"notification-client-key-file",
+		"",
+		"The PEM file of the key of the client certificate presented to the notification endpoints.",
+	)
+
+	flag.BoolVar(
+		&Options.NotificationInsecure,
+		"notification-insecure-skip-verify",
+		false,
+		"Skip the verification of the certificates of the notification endpoints.",
+	)
+
+	flag.IntVar(
+		&Options.HistoryLimit,
+		"history-limit",
+		sync.DefaultHistoryLimit,
+		"The number of compliance history entries kept per policy template.",
+	)
+
+	flag.StringVar(
+		&Options.AuditLogPath,
+		"audit-log",
+		"",
+		"The file to which every change made by the controller is recorded in the JSON Lines format, or \"-\" "+
+			"for stdout. Stdout then only contains the audit records: the logs are written to stderr, and so are "+
+			"the preflight report and the console traces. The audit log is disabled when empty.",
+	)
+
+	flag.IntVar(
+		&Options.AuditLogMaxSizeMB,
+		"audit-log-max-size",
+		100,
+		"The size in megabytes after which the audit log file is rotated. Zero disables the rotation.",
+	)
+
+	flag.IntVar(
+		&Options.AuditLogMaxBackups,
+		"audit-log-max-backups",
+		5,
+		"The number of rotated audit log files that are kept.",
+	)
+
+	flag.StringVar(
+		&Options.DebugAddr,
+		"debug-bind-address",
+		"",
+		"The address the debug endpoint, which serves the sync state of every policy at "+
+			"/debug/policies, binds to. It is unauthenticated, so bind it to a local address such as "+
+			"\"127.0.0.1:8090\". The debug endpoint is disabled when empty.",
+	)
+
+	flag.StringVar(
+		&Options.LogLevelAddr,
+		"log-level-bind-address",
+		"",
+		"The address the log level endpoint, which gets and sets the log level of each logger at /loglevel, binds "+
+			"to. Bind it to a local address such as \"127.0.0.1:8091\". The endpoint is disabled when empty.",
+	)
+
+	flag.StringVar(
+		&Options.LogLevelTokenFile,
+		"log-level-token-file",
+		"",
+		"The file with the bearer token required by the log level endpoint.",
+	)
+
+	flag.BoolVar(
+		&Options.LogLevelSignals,
+		"log-level-signals",
+		false,
+		"If enabled, SIGUSR1 increases the verbosity of every logger by one and SIGUSR2 restores the log levels "+
+			"at startup.",
+	)
+
+	flag.StringVar(
+		&Options.MessageRedactionRules,
+		"message-redaction-rules",
+		"",
+		"A YAML file with a list of redaction rules, each with a regular expression \"pattern\" and its "+
+			"\"replacement\", applied to the history messages before they are synced to the hub.",
+	)
+
+	flag.IntVar(
+		&Options.MessageMaxLength,
+		"message-max-length",
+		0,
+		fmt.Sprintf("The maximum number of characters of a history message synced to the hub, longer messages "+
+			"are truncated. It must be at least %d to keep the compliance state of the messages. Use 0 to disable "+
+			"the truncation.", sync.MinMessageMaxLength),
+	)
+
+	flag.BoolVar(
+		&Options.KeepFullManagedMessages,
+		"keep-full-managed-messages",
+		false,
+		"If enabled, the message redaction only applies to the hub status and the notifications, and the managed "+
+			"policy status keeps the full messages.",
+	)
+
+	flag.IntVar(
+		&Options.StatusSizeBudget,
+		"status-size-budget",
+		sync.DefaultStatusSizeBudget,
+		"The maximum size in bytes of a policy with its status. Larger statuses are reduced by shrinking the history "+
+			"of each policy template, then truncating the messages, then dropping the oldest entries, which is "+
+			"reported by the StatusTruncated condition. Use 0 to disable the size guard.",
+	)
+}
+
+// StaleThresholdPerKind returns the staleness thresholds of the policy template kinds from the command line.
+func StaleThresholdPerKind() (map[string]time.Duration, error) {
+	thresholds := make(map[string]time.Duration, len(Options.StaleThresholdPerKind))
+
+	for kind, value := range Options.StaleThresholdPerKind {
+		threshold, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid staleness threshold for the %s kind: %w", kind, err)
+		}
+
+		thresholds[kind] = threshold
+	}
+
+	return thresholds, nil
+}
+
+// RateLimiter returns the rate limiter of the reconcile requests. It has the same structure as the
+// controller-runtime default, with per-policy exponential backoff and an overall token bucket, but uses the
+// values from the command line.
+func RateLimiter() workqueue.RateLimiter {
+	return workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(Options.RateLimiterBaseDelay, Options.RateLimiterMaxDelay),
+		&workqueue.BucketRateLimiter{
+			Limiter: rate.NewLimiter(rate.Limit(Options.RateLimiterQPS), Options.RateLimiterBurst),
+		},
+	)
+}
+
+// ConfigureClient applies the QPS, burst and timeout settings to the input REST config. Zero values leave the
+// corresponding setting of the REST config untouched.
+func ConfigureClient(cfg *rest.Config, qps float32, burst int, timeout time.Duration) {
+	if qps != 0 {
+		cfg.QPS = qps
+	}
+
+	if burst != 0 {
+		cfg.Burst = burst
+	}
+
+	if timeout != 0 {
+		cfg.Timeout = timeout
+	}
 }
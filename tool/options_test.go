@@ -0,0 +1,79 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package tool
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+func TestConfigureClient(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		qps      float32
+		burst    int
+		timeout  time.Duration
+		expected rest.Config
+	}{
+		"defaults kept": {
+			expected: rest.Config{QPS: 5, Burst: 10, Timeout: time.Minute},
+		},
+		"all set": {
+			qps: 50, burst: 100, timeout: 30 * time.Second,
+			expected: rest.Config{QPS: 50, Burst: 100, Timeout: 30 * time.Second},
+		},
+		"only the burst": {
+			burst:    20,
+			expected: rest.Config{QPS: 5, Burst: 20, Timeout: time.Minute},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &rest.Config{QPS: 5, Burst: 10, Timeout: time.Minute}
+			ConfigureClient(cfg, test.qps, test.burst, test.timeout)
+
+			if cfg.QPS != test.expected.QPS || cfg.Burst != test.expected.Burst ||
+				cfg.Timeout != test.expected.Timeout {
+				t.Fatalf("expected QPS %v, burst %d and timeout %s, got %v, %d and %s", test.expected.QPS,
+					test.expected.Burst, test.expected.Timeout, cfg.QPS, cfg.Burst, cfg.Timeout)
+			}
+		})
+	}
+}
+
+// TestRateLimiter isn't parallel since it sets the global options.
+func TestRateLimiter(t *testing.T) {
+	previous := Options
+	defer func() { Options = previous }()
+
+	Options.RateLimiterBaseDelay = 10 * time.Millisecond
+	Options.RateLimiterMaxDelay = 30 * time.Millisecond
+	Options.RateLimiterQPS = 1000
+	Options.RateLimiterBurst = 1000
+
+	limiter := RateLimiter()
+
+	for i, expected := range []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond} {
+		if delay := limiter.When("policy"); delay != expected {
+			t.Fatalf("expected the delay %s after %d failures, got %s", expected, i, delay)
+		}
+	}
+
+	if delay := limiter.When("other-policy"); delay != 10*time.Millisecond {
+		t.Fatalf("expected the backoff of each policy to be independent, got %s", delay)
+	}
+
+	limiter.Forget("policy")
+
+	if delay := limiter.When("policy"); delay != 10*time.Millisecond {
+		t.Fatalf("expected the backoff to restart after a success, got %s", delay)
+	}
+}
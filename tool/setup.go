@@ -0,0 +1,615 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
+	"open-cluster-management.io/addon-framework/pkg/lease"
+	addonutils "open-cluster-management.io/addon-framework/pkg/utils"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"open-cluster-management.io/governance-policy-status-sync/controllers/sync"
+)
+
+// hubConfigCheckInterval is how often the hub kubeconfig file is checked for changes when it is reloaded in place
+const hubConfigCheckInterval = 10 * time.Second
+
+var setupLog = ctrl.Log.WithName("setup")
+
+// Setup builds the policy status sync controller, and runs it or its subcommands, from the command line flags.
+type Setup struct {
+	// Scheme is the scheme of the hub and managed clients
+	Scheme *runtime.Scheme
+	// EventsScheme is the scheme of the hub event recorder
+	EventsScheme *runtime.Scheme
+	HubConfig    *rest.Config
+	// ManagedConfig is the managed cluster config, without the request timeout since the manager cache watches with it
+	ManagedConfig *rest.Config
+	// ManagedClientTimeout is the request timeout of the managed cluster clients that don't watch
+	ManagedClientTimeout  time.Duration
+	WatchNamespace        string
+	ClusterNamespaceOnHub string
+}
+
+// NewSetup returns a Setup with the hub and managed cluster configs, and the watch and hub cluster namespaces, from
+// the command line flags and the environment.
+func NewSetup(scheme *runtime.Scheme, eventsScheme *runtime.Scheme) (*Setup, error) {
+	s := &Setup{Scheme: scheme, EventsScheme: eventsScheme}
+
+	// Get hubconfig to talk to hub apiserver
+	if Options.HubConfigFilePathName == "" {
+		var found bool
+
+		Options.HubConfigFilePathName, found = os.LookupEnv("HUB_CONFIG")
+		if found {
+			setupLog.Info("Found ENV HUB_CONFIG, initializing using", "tool.Options.HubConfigFilePathName",
+				Options.HubConfigFilePathName)
+		}
+	}
+
+	var err error
+
+	s.HubConfig, err = clientcmd.BuildConfigFromFlags("", Options.HubConfigFilePathName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build the hub cluster config: %w", err)
+	}
+
+	ConfigureClient(s.HubConfig, Options.HubClientQPS, Options.HubClientBurst, Options.HubClientTimeout)
+
+	// Get managedconfig to talk to managed apiserver
+	if Options.ManagedConfigFilePathName == "" {
+		var found bool
+
+		Options.ManagedConfigFilePathName, found = os.LookupEnv("MANAGED_CONFIG")
+		if found {
+			setupLog.Info("Found ENV MANAGED_CONFIG, initializing using", "tool.Options.ManagedConfigFilePathName",
+				Options.ManagedConfigFilePathName)
+		}
+	}
+
+	if Options.ManagedConfigFilePathName == "" {
+		s.ManagedConfig, err = config.GetConfig()
+	} else {
+		s.ManagedConfig, err = clientcmd.BuildConfigFromFlags("", Options.ManagedConfigFilePathName)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to build the managed cluster config: %w", err)
+	}
+
+	// The timeout of a REST config bounds the whole HTTP request, so it would also end the watches of the manager cache
+	ConfigureClient(s.ManagedConfig, Options.ManagedClientQPS, Options.ManagedClientBurst, 0)
+	s.ManagedClientTimeout = Options.ManagedClientTimeout
+
+	s.WatchNamespace, err = GetWatchNamespace()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the watch namespace: %w", err)
+	}
+
+	if Options.ClusterNamespaceOnHub == "" {
+		s.ClusterNamespaceOnHub = s.WatchNamespace
+	} else {
+		s.ClusterNamespaceOnHub = Options.ClusterNamespaceOnHub
+		setupLog.Info(
+			"The Hub will receive status updates in the input cluster namespace", "namespace", s.ClusterNamespaceOnHub,
+		)
+	}
+
+	return s, nil
+}
+
+// withManagedTimeout returns a copy of the input managed cluster config with the request timeout, for the clients that
+// don't watch.
+func (s *Setup) withManagedTimeout(managedCfg *rest.Config) *rest.Config {
+	cfg := rest.CopyConfig(managedCfg)
+	if s.ManagedClientTimeout != 0 {
+		cfg.Timeout = s.ManagedClientTimeout
+	}
+
+	return cfg
+}
+
+// newHubClients returns a client to the hub cluster and an event recorder, backed by the returned broadcaster, that
+// records events in the cluster namespace on the hub.
+func (s *Setup) newHubClients(
+	hubCfg *rest.Config,
+) (client.Client, record.EventRecorder, record.EventBroadcaster, error) {
+	hubClient, err := client.New(hubCfg, client.Options{Scheme: s.Scheme})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	kubeClient, err := kubernetes.NewForConfig(hubCfg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(
+		&corev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events(s.ClusterNamespaceOnHub)},
+	)
+
+	hubRecorder := eventBroadcaster.NewRecorder(s.EventsScheme, v1.EventSource{Component: sync.ControllerName})
+
+	return hubClient, hubRecorder, eventBroadcaster, nil
+}
+
+// ConsoleOutput returns the writer of the output other than the logs and the audit log, such as the preflight report
+// and the console traces. It is stdout, unless the audit log is written to stdout, in which case it is stderr so that
+// stdout only contains the audit records.
+func ConsoleOutput() io.Writer {
+	if Options.AuditLogPath == "-" {
+		return os.Stderr
+	}
+
+	return os.Stdout
+}
+
+// RunPreflight verifies the RBAC permissions required on the hub and managed clusters, writes a report to the input
+// writer and returns whether all the permissions are allowed.
+func (s *Setup) RunPreflight(ctx context.Context, out io.Writer) (bool, error) {
+	hubKubeClient, err := kubernetes.NewForConfig(s.HubConfig)
+	if err != nil {
+		return false, err
+	}
+
+	managedKubeClient, err := kubernetes.NewForConfig(s.withManagedTimeout(s.ManagedConfig))
+	if err != nil {
+		return false, err
+	}
+
+	hubResults := sync.CheckPermissions(
+		ctx, hubKubeClient.AuthorizationV1().SelfSubjectAccessReviews(), sync.HubPermissions(s.ClusterNamespaceOnHub),
+	)
+
+	managedPermissions := []sync.Permission{}
+	for _, ns := range strings.Split(s.WatchNamespace, ",") {
+		managedPermissions = append(managedPermissions, sync.ManagedPermissions(ns)...)
+
+		if Options.ExportPolicyReports {
+			managedPermissions = append(managedPermissions, sync.PolicyReportPermissions(ns)...)
+		}
+	}
+
+	if Options.EnableLease {
+		if operatorNs, err := GetOperatorNamespace(); err == nil {
+			managedPermissions = append(managedPermissions, sync.LeasePermissions(operatorNs)...)
+		}
+	}
+
+	managedResults := sync.CheckPermissions(
+		ctx, managedKubeClient.AuthorizationV1().SelfSubjectAccessReviews(), managedPermissions,
+	)
+
+	hubPassed, err := sync.WritePermissionReport(out, "hub", hubResults)
+	if err != nil {
+		return false, err
+	}
+
+	managedPassed, err := sync.WritePermissionReport(out, "managed", managedResults)
+	if err != nil {
+		return false, err
+	}
+
+	if hubPassed && managedPassed {
+		_, err = fmt.Fprintln(out, "Preflight check passed")
+	} else {
+		_, err = fmt.Fprintln(out, "Preflight check failed")
+	}
+
+	return hubPassed && managedPassed, err
+}
+
+// StatusOptions returns the reconciler options, set from the command line flags, that affect the computed status and
+// the status written to the hub.
+func StatusOptions() ([]sync.Option, error) {
+	staleThresholdPerKind, err := StaleThresholdPerKind()
+	if err != nil {
+		return nil, fmt.Errorf("invalid --stale-threshold-per-kind flag: %w", err)
+	}
+
+	redaction := sync.MessageRedaction{
+		MaxLength:         Options.MessageMaxLength,
+		KeepFullOnManaged: Options.KeepFullManagedMessages,
+	}
+
+	if Options.MessageRedactionRules != "" {
+		redaction.Rules, err = sync.LoadRedactionRules(Options.MessageRedactionRules)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --message-redaction-rules flag: %w", err)
+		}
+	}
+
+	return []sync.Option{
+		sync.WithHistoryLimit(Options.HistoryLimit),
+		sync.WithMetadataRules(sync.MetadataSyncRules{
+			HubOwnedLabels:          Options.HubOwnedLabels,
+			ManagedLocalLabels:      Options.ManagedLocalLabels,
+			HubOwnedAnnotations:     Options.HubOwnedAnnotations,
+			ManagedLocalAnnotations: Options.ManagedLocalAnnotations,
+			PreserveMissingHubOwned: Options.PreserveMissingMetadata,
+		}),
+		sync.WithStaleThresholds(Options.StaleThreshold, staleThresholdPerKind),
+		sync.WithFlapDetection(Options.FlapThreshold, Options.FlapWindow, Options.FlapDampingPeriod),
+		sync.WithMessageRedaction(redaction),
+		sync.WithStatusSizeBudget(Options.StatusSizeBudget),
+	}, nil
+}
+
+// RunInspect writes to the input writer the managed, hub and computed statuses of the input managed policy, and the
+// spec mismatch between the managed and hub policies. Nothing is written to the clusters.
+func (s *Setup) RunInspect(ctx context.Context, name string, out io.Writer) error {
+	if strings.Contains(s.WatchNamespace, ",") {
+		return errors.New("the inspect subcommand requires a single watch namespace")
+	}
+
+	hubClient, err := client.New(s.HubConfig, client.Options{Scheme: s.Scheme})
+	if err != nil {
+		return err
+	}
+
+	managedClient, err := client.New(s.withManagedTimeout(s.ManagedConfig), client.Options{Scheme: s.Scheme})
+	if err != nil {
+		return err
+	}
+
+	statusOpts, err := StatusOptions()
+	if err != nil {
+		return err
+	}
+
+	reconciler, err := sync.NewPolicyReconciler(append([]sync.Option{
+		sync.WithHub(hubClient, &record.FakeRecorder{}),
+		sync.WithManaged(managedClient, &record.FakeRecorder{}, s.Scheme),
+		sync.WithClusterNamespaceOnHub(s.ClusterNamespaceOnHub),
+	}, statusOpts...)...)
+	if err != nil {
+		return err
+	}
+
+	return reconciler.Inspect(ctx, types.NamespacedName{Namespace: s.WatchNamespace, Name: name}, out)
+}
+
+// newManager returns the manager of the controller, which watches the watch namespaces on the managed cluster.
+func (s *Setup) newManager() (manager.Manager, error) {
+	options := manager.Options{
+		LeaderElection:         Options.EnableLeaderElection,
+		LeaderElectionID:       "policy-status-sync.open-cluster-management.io",
+		HealthProbeBindAddress: Options.ProbeAddr,
+		MetricsBindAddress:     Options.MetricsAddr,
+		Namespace:              s.WatchNamespace,
+		Scheme:                 s.Scheme,
+		// only the client, which reads from the cache and writes directly, has the request timeout
+		NewClient: func(
+			objectCache cache.Cache, cfg *rest.Config, clientOptions client.Options, uncachedObjects ...client.Object,
+		) (client.Client, error) {
+			return cluster.DefaultNewClient(objectCache, s.withManagedTimeout(cfg), clientOptions, uncachedObjects...)
+		},
+	}
+	if Options.LegacyLeaderElection {
+		// If legacyLeaderElection is enabled, then that means the lease API is not available.
+		// In this case, use the legacy leader election method of a ConfigMap.
+		options.LeaderElectionResourceLock = "configmaps"
+	} else {
+		// use the leases leader election by default for controller-runtime 0.11 instead of
+		// the default of configmapsleases (leases is the new default in 0.12)
+		options.LeaderElectionResourceLock = "leases"
+	}
+	// Add support for MultiNamespace set in WATCH_NAMESPACE (e.g ns1,ns2)
+	// Note that this is not intended to be used for excluding namespaces, this is better done via a Predicate
+	// Also note that you may face performance issues when using this with a high number of namespaces.
+	// More Info: https://godoc.org/github.com/kubernetes-sigs/controller-runtime/pkg/cache#MultiNamespacedCacheBuilder
+	if strings.Contains(s.WatchNamespace, ",") {
+		options.Namespace = ""
+		options.NewCache = cache.MultiNamespacedCacheBuilder(strings.Split(s.WatchNamespace, ","))
+	}
+
+	return ctrl.NewManager(s.ManagedConfig, options)
+}
+
+// reconcilerOptions returns the options of the reconciler from the command line flags. The notification sink is added
+// to the input manager.
+func (s *Setup) reconcilerOptions(
+	mgr manager.Manager, hubClient client.Client, hubRecorder record.EventRecorder,
+) ([]sync.Option, error) {
+	statusOpts, err := StatusOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	reconcilerOptions := append([]sync.Option{
+		sync.WithHub(hubClient, hubRecorder),
+		sync.WithManager(mgr),
+		sync.WithClusterNamespaceOnHub(s.ClusterNamespaceOnHub),
+		sync.WithConcurrency(Options.MaxConcurrentReconciles, RateLimiter()),
+		sync.WithResyncPeriod(Options.ResyncPeriod),
+		sync.WithStatusOnly(Options.StatusOnly),
+		sync.WithDeletionSafeguards(Options.DeletionGracePeriod, Options.MassDeletionThreshold),
+		sync.WithRecoveryBackoff(
+			Options.RecoveryFightThreshold, Options.RecoveryFightWindow, Options.RecoveryBackoffMax,
+		),
+		sync.WithPolicyReports(Options.ExportPolicyReports),
+	}, statusOpts...)
+
+	if len(Options.NotificationEndpoints) > 0 {
+		source := Options.NotificationSource
+		if source == "" {
+			source = s.ClusterNamespaceOnHub
+		}
+
+		notificationSink, err := sync.NewCloudEventsSink(sync.CloudEventsSinkOptions{
+			Endpoints:          Options.NotificationEndpoints,
+			Source:             source,
+			QueueSize:          Options.NotificationQueueSize,
+			MaxRetries:         Options.NotificationMaxRetries,
+			RetryDelay:         Options.NotificationRetryDelay,
+			Timeout:            Options.NotificationTimeout,
+			CAFile:             Options.NotificationCAFile,
+			CertFile:           Options.NotificationCertFile,
+			KeyFile:            Options.NotificationKeyFile,
+			InsecureSkipVerify: Options.NotificationInsecure,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure the compliance change notifications: %w", err)
+		}
+
+		if err := mgr.Add(notificationSink); err != nil {
+			return nil, fmt.Errorf("unable to add the notification sink to the manager: %w", err)
+		}
+
+		reconcilerOptions = append(reconcilerOptions, sync.WithNotificationSinks(notificationSink))
+	}
+
+	if Options.AuditLogPath != "" {
+		auditLog, err := sync.NewAuditLog(
+			Options.AuditLogPath, int64(Options.AuditLogMaxSizeMB)*1024*1024, Options.AuditLogMaxBackups,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open the audit log %s: %w", Options.AuditLogPath, err)
+		}
+
+		reconcilerOptions = append(reconcilerOptions, sync.WithAuditLog(auditLog))
+	}
+
+	if Options.ExportPolicyReports {
+		_, err = mgr.GetRESTMapper().RESTMapping(sync.PolicyReportGVK.GroupKind(), sync.PolicyReportGVK.Version)
+		if err != nil {
+			return nil, fmt.Errorf("the PolicyReport CRD must be installed to export the policy reports: %w", err)
+		}
+	}
+
+	return reconcilerOptions, nil
+}
+
+// addServers adds the log level and debug endpoints, when enabled, to the input manager.
+func addServers(mgr manager.Manager, reconciler *sync.PolicyReconciler, logLevels *LogLevels) error {
+	if Options.LogLevelAddr != "" || Options.LogLevelSignals {
+		logLevelServer := &LogLevelServer{
+			Levels:  logLevels,
+			Addr:    Options.LogLevelAddr,
+			Signals: Options.LogLevelSignals,
+		}
+
+		if Options.LogLevelAddr != "" {
+			token, err := os.ReadFile(Options.LogLevelTokenFile)
+			if err != nil || strings.TrimSpace(string(token)) == "" {
+				return fmt.Errorf("the log level endpoint requires a token file %q: %v", Options.LogLevelTokenFile, err)
+			}
+
+			logLevelServer.Token = strings.TrimSpace(string(token))
+		}
+
+		if err := mgr.Add(logLevelServer); err != nil {
+			return fmt.Errorf("unable to set up the log level endpoint: %w", err)
+		}
+	}
+
+	if Options.DebugAddr != "" {
+		debugServer := &DebugServer{Addr: Options.DebugAddr, Handler: reconciler.DebugHandler()}
+		if err := mgr.Add(debugServer); err != nil {
+			return fmt.Errorf("unable to set up the debug endpoint: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// addHealthChecks adds the health and readiness checks to the input manager. When the hub kubeconfig is reloaded in
+// place, the hub clients of the reconciler, the input hub transport and the event broadcaster are replaced on its
+// changes, otherwise the health check fails when it changes so that the controller is restarted.
+func (s *Setup) addHealthChecks(
+	mgr manager.Manager, reconciler *sync.PolicyReconciler, hubTransport *HubTransport,
+	eventBroadcaster record.EventBroadcaster,
+) error {
+	if Options.ReloadHubConfig {
+		hubConfigWatcher, err := NewHubConfigWatcher(
+			Options.HubConfigFilePathName,
+			hubConfigCheckInterval,
+			func(hubCfg *rest.Config) error {
+				newHubClient, newHubRecorder, newEventBroadcaster, err := s.newHubClients(hubCfg)
+				if err != nil {
+					return err
+				}
+
+				if err := hubTransport.Set(hubCfg); err != nil {
+					return err
+				}
+
+				reconciler.SetHubClients(newHubClient, newHubRecorder)
+				// No reconcile uses the previous recorder anymore, so its broadcaster can be stopped
+				eventBroadcaster.Shutdown()
+				eventBroadcaster = newEventBroadcaster
+
+				return nil
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("unable to set up the hub config watcher: %w", err)
+		}
+
+		if err := mgr.Add(hubConfigWatcher); err != nil {
+			return fmt.Errorf("unable to set up the hub config watcher: %w", err)
+		}
+
+		if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
+			return fmt.Errorf("unable to set up health check: %w", err)
+		}
+	} else {
+		// use config check
+		configChecker, err := addonutils.NewConfigChecker("policy-status-sync", Options.HubConfigFilePathName)
+		if err != nil {
+			return fmt.Errorf("unable to setup a configChecker: %w", err)
+		}
+
+		if err := mgr.AddHealthzCheck("healthz", configChecker.Check); err != nil {
+			return fmt.Errorf("unable to set up health check: %w", err)
+		}
+	}
+
+	for name, check := range reconciler.ReadyzChecks(mgr.GetCache(), Options.ReadinessTimeout) {
+		if err := mgr.AddReadyzCheck(name, check); err != nil {
+			return fmt.Errorf("unable to set up the %s ready check: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// startLease starts the lease updater, which is not related to leader election. This is to report the status of the
+// controller to the addon framework. This can be seen in the "status" section of the ManagedClusterAddOn resource
+// objects.
+func (s *Setup) startLease(ctx context.Context, hubTransport *HubTransport) error {
+	if !Options.EnableLease {
+		setupLog.Info("Status reporting is not enabled")
+
+		return nil
+	}
+
+	operatorNs, err := GetOperatorNamespace()
+	if err != nil {
+		if errors.Is(err, ErrNoNamespace) || errors.Is(err, ErrRunLocal) {
+			setupLog.Info("Skipping lease; not running in a cluster.")
+
+			return nil
+		}
+
+		return fmt.Errorf("failed to get operator namespace: %w", err)
+	}
+
+	setupLog.Info("Starting lease controller to report status")
+
+	generatedClient, err := kubernetes.NewForConfig(s.withManagedTimeout(s.ManagedConfig))
+	if err != nil {
+		return err
+	}
+
+	leaseUpdater := lease.NewLeaseUpdater(
+		generatedClient,
+		"governance-policy-framework",
+		operatorNs,
+		lease.CheckAddonPodFunc(generatedClient.CoreV1(), operatorNs, "app=governance-policy-framework"),
+	).WithHubLeaseConfig(hubTransport.Config(s.HubConfig), s.WatchNamespace)
+	go leaseUpdater.Start(ctx)
+
+	return nil
+}
+
+// Run builds the controller and its manager, and runs them until the input context is done. The input log levels
+// are served by the log level endpoint.
+func (s *Setup) Run(ctx context.Context, logLevels *LogLevels) error {
+	hubClient, hubRecorder, eventBroadcaster, err := s.newHubClients(s.HubConfig)
+	if err != nil {
+		return fmt.Errorf("failed to generate client to the hub cluster: %w", err)
+	}
+
+	mgr, err := s.newManager()
+	if err != nil {
+		return fmt.Errorf("unable to start manager: %w", err)
+	}
+
+	reconcilerOptions, err := s.reconcilerOptions(mgr, hubClient, hubRecorder)
+	if err != nil {
+		return err
+	}
+
+	reconciler, err := sync.NewPolicyReconciler(reconcilerOptions...)
+	if err != nil {
+		return fmt.Errorf("invalid controller configuration: %w", err)
+	}
+
+	defer func() {
+		if err := reconciler.AuditLog.Close(); err != nil {
+			setupLog.Error(err, "Failed to close the audit log")
+		}
+	}()
+
+	if err = reconciler.SetupWithManager(mgr); err != nil {
+		return fmt.Errorf("unable to create the Policy controller: %w", err)
+	}
+
+	//+kubebuilder:scaffold:builder
+
+	if err := addServers(mgr, reconciler, logLevels); err != nil {
+		return err
+	}
+
+	// The hub client of the lease updater can't be rebuilt, so its requests go through a transport that follows the
+	// hub kubeconfig changes
+	hubTransport, err := NewHubTransport(s.HubConfig)
+	if err != nil {
+		return fmt.Errorf("failed to build the hub transport: %w", err)
+	}
+
+	if err := s.addHealthChecks(mgr, reconciler, hubTransport, eventBroadcaster); err != nil {
+		return err
+	}
+
+	// the lease updater can't be stopped, so it isn't bound to the manager
+	if err := s.startLease(context.TODO(), hubTransport); err != nil {
+		return err
+	}
+
+	shutdownTracing, err := SetupTracing(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to configure the tracing: %w", err)
+	}
+
+	setupLog.Info("starting manager")
+
+	if err := mgr.Start(ctx); err != nil {
+		return fmt.Errorf("problem running manager: %w", err)
+	}
+
+	if shutdownTracing != nil {
+		// export the remaining spans, since the input context is already done
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			setupLog.Error(err, "Failed to export the remaining spans")
+		}
+	}
+
+	return nil
+}
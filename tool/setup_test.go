@@ -0,0 +1,122 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package tool
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// TestNewSetup isn't parallel since it sets the global options and the environment.
+func TestNewSetup(t *testing.T) {
+	previous := Options
+	defer func() { Options = previous }()
+
+	dir := t.TempDir()
+	for name, content := range map[string][]byte{
+		"hub-kubeconfig":     hubKubeconfig("https://hub:6443"),
+		"managed-kubeconfig": hubKubeconfig("https://managed:6443"),
+		"tls.crt":            []byte("certificate"),
+		"tls.key":            []byte("key"),
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	t.Setenv("HUB_CONFIG", filepath.Join(dir, "hub-kubeconfig"))
+	t.Setenv("MANAGED_CONFIG", filepath.Join(dir, "managed-kubeconfig"))
+	t.Setenv("WATCH_NAMESPACE", "managed")
+
+	Options = PolicySpecSyncOptions{HubClientQPS: 50, ManagedClientTimeout: time.Minute}
+
+	setup, err := NewSetup(runtime.NewScheme(), runtime.NewScheme())
+	if err != nil {
+		t.Fatalf("failed to build the setup: %v", err)
+	}
+
+	if setup.HubConfig.Host != "https://hub:6443" || setup.HubConfig.QPS != 50 {
+		t.Fatalf("expected the hub config from HUB_CONFIG with the hub client settings, got %s and %v",
+			setup.HubConfig.Host, setup.HubConfig.QPS)
+	}
+
+	if setup.ManagedConfig.Host != "https://managed:6443" || setup.ManagedConfig.Timeout != 0 {
+		t.Fatalf("expected the managed config from MANAGED_CONFIG without a timeout, got %s and %s",
+			setup.ManagedConfig.Host, setup.ManagedConfig.Timeout)
+	}
+
+	// the timeout would end the watches of the manager cache, so it only applies to the other clients
+	if timeout := setup.withManagedTimeout(setup.ManagedConfig).Timeout; timeout != time.Minute {
+		t.Fatalf("expected the managed client timeout for the clients that don't watch, got %s", timeout)
+	}
+
+	if setup.WatchNamespace != "managed" || setup.ClusterNamespaceOnHub != "managed" {
+		t.Fatalf("expected the cluster namespace on the hub to default to the watch namespace, got %s and %s",
+			setup.WatchNamespace, setup.ClusterNamespaceOnHub)
+	}
+
+	Options.ClusterNamespaceOnHub = "cluster"
+
+	setup, err = NewSetup(runtime.NewScheme(), runtime.NewScheme())
+	if err != nil {
+		t.Fatalf("failed to build the setup: %v", err)
+	}
+
+	if setup.ClusterNamespaceOnHub != "cluster" {
+		t.Fatalf("expected the cluster namespace on the hub from the flag, got %s", setup.ClusterNamespaceOnHub)
+	}
+
+	t.Setenv("HUB_CONFIG", filepath.Join(dir, "missing"))
+
+	Options.HubConfigFilePathName = ""
+
+	if _, err := NewSetup(runtime.NewScheme(), runtime.NewScheme()); err == nil {
+		t.Fatal("expected an error for a missing hub kubeconfig")
+	}
+}
+
+// TestStatusOptions isn't parallel since it sets the global options.
+func TestStatusOptions(t *testing.T) {
+	previous := Options
+	defer func() { Options = previous }()
+
+	Options = PolicySpecSyncOptions{}
+
+	if _, err := StatusOptions(); err != nil {
+		t.Fatalf("unexpected error for the default flags: %v", err)
+	}
+
+	Options.StaleThresholdPerKind = map[string]string{"ConfigurationPolicy": "soon"}
+
+	if _, err := StatusOptions(); err == nil {
+		t.Fatal("expected an error for an invalid stale threshold")
+	}
+
+	Options = PolicySpecSyncOptions{MessageRedactionRules: filepath.Join(t.TempDir(), "missing")}
+
+	if _, err := StatusOptions(); err == nil {
+		t.Fatal("expected an error for missing redaction rules")
+	}
+}
+
+// TestConsoleOutput isn't parallel since it sets the global options.
+func TestConsoleOutput(t *testing.T) {
+	previous := Options
+	defer func() { Options = previous }()
+
+	Options = PolicySpecSyncOptions{AuditLogPath: "audit.log"}
+
+	if ConsoleOutput() != os.Stdout {
+		t.Fatal("expected the console output on stdout with an audit log file")
+	}
+
+	Options = PolicySpecSyncOptions{AuditLogPath: "-"}
+
+	if ConsoleOutput() != os.Stderr {
+		t.Fatal("expected the console output on stderr with the audit log on stdout")
+	}
+}
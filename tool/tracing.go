@@ -0,0 +1,84 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package tool
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+// TracingServiceName is the default service name of the traces, overridden by OTEL_SERVICE_NAME
+const TracingServiceName = "governance-policy-status-sync"
+
+// SetupTracing configures the global OpenTelemetry tracer provider from the standard OTEL environment variables.
+// OTEL_TRACES_EXPORTER selects the exporter: "otlp", whose protocol is set by OTEL_EXPORTER_OTLP_TRACES_PROTOCOL or
+// OTEL_EXPORTER_OTLP_PROTOCOL ("http/protobuf" by default, or "grpc"), or "console" for the ConsoleOutput, which is
+// stdout unless the audit log is written there. Tracing is disabled
+// when it is unset or "none", or when OTEL_SDK_DISABLED is "true". The endpoint, headers, sampler, resource
+// attributes and batching are read by the OpenTelemetry SDK from their own variables. The returned function flushes
+// and stops the tracer provider; it is nil when tracing is disabled.
+func SetupTracing(ctx context.Context) (func(context.Context) error, error) {
+	exporterName := strings.ToLower(strings.TrimSpace(os.Getenv("OTEL_TRACES_EXPORTER")))
+	if exporterName == "" || exporterName == "none" || strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return nil, nil
+	}
+
+	var exporter sdktrace.SpanExporter
+
+	var err error
+
+	switch exporterName {
+	case "otlp":
+		protocol := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL")
+		if protocol == "" {
+			protocol = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+		}
+
+		switch protocol {
+		case "", "http/protobuf":
+			exporter, err = otlptracehttp.New(ctx)
+		case "grpc":
+			exporter, err = otlptracegrpc.New(ctx)
+		default:
+			return nil, fmt.Errorf("unsupported OTLP protocol %q, expected http/protobuf or grpc", protocol)
+		}
+	case "console", "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(ConsoleOutput()))
+	default:
+		return nil, fmt.Errorf("unsupported OTEL_TRACES_EXPORTER %q, expected otlp, console or none", exporterName)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the %s trace exporter: %w", exporterName, err)
+	}
+
+	res, err := resource.Merge(
+		resource.NewSchemaless(semconv.ServiceName(TracingServiceName)),
+		resource.Environment(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build the tracing resource: %w", err)
+	}
+
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+
+	otel.SetTracerProvider(tracerProvider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+
+	log.Info("Tracing is enabled", "exporter", exporterName)
+
+	return tracerProvider.Shutdown, nil
+}